@@ -3,6 +3,7 @@ module github.com/eventhunt-org/webapp
 go 1.23
 
 require (
+	github.com/arran4/golang-ical v0.3.2
 	github.com/go-chi/chi/v5 v5.1.0
 	github.com/go-playground/validator/v10 v10.22.1
 	github.com/go-sql-driver/mysql v1.7.0
@@ -12,8 +13,10 @@ require (
 	github.com/lmittmann/tint v1.0.5
 	github.com/magefile/mage v1.15.0
 	github.com/sirupsen/logrus v1.8.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/cobra v1.8.1
 	github.com/spf13/viper v1.19.0
+	github.com/teambition/rrule-go v1.8.2
 	golang.org/x/crypto v0.27.0
 )
 
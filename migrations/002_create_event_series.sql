@@ -0,0 +1,45 @@
+-- Recurring event series. Occurrences are materialized as regular rows in
+-- app.events so that everything built around events keeps working.
+
+CREATE TABLE app.event_series (
+	id				BIGSERIAL		PRIMARY KEY,
+	group_id		INTEGER			NOT NULL references app.groups(id),
+	user_id			INTEGER			NOT NULL references app.users(id),
+	name			varchar(80)		NOT NULL,
+	summary			varchar(255)	NOT NULL	DEFAULT '',
+	description		TEXT			NOT NULL	DEFAULT '',
+	web_url			varchar(1000)	NOT NULL	DEFAULT '',
+	attendee_limit	INTEGER			NOT NULL	DEFAULT 0,
+	venue_id		INTEGER			references app.venues(id) DEFAULT null,
+	location_url	varchar(1000)	NOT NULL	DEFAULT '',
+	-- RFC 5545 RRULE value, without the DTSTART line
+	rrule			varchar(500)	NOT NULL,
+	-- the first occurrence, in UTC
+	start_time		timestamp		NOT NULL,
+	end_time		timestamp		NOT NULL,
+	-- IANA timezone the rule is expanded in so that DST is respected
+	timezone		varchar(40)		NOT NULL,
+	exdates			timestamp[]		NOT NULL	DEFAULT '{}',
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE app.events
+	ADD COLUMN series_id		BIGINT		references app.event_series(id) DEFAULT null,
+	-- the original start of the occurrence, the RECURRENCE-ID in RFC 5545
+	ADD COLUMN recurrence_time	timestamp	DEFAULT null,
+	-- an override is an occurrence edited on its own, series edits skip it
+	ADD COLUMN is_override		boolean		NOT NULL	DEFAULT false;
+
+CREATE INDEX events_series_idx ON app.events (series_id, recurrence_time);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS app.events_series_idx;
+
+ALTER TABLE app.events
+	DROP COLUMN IF EXISTS is_override,
+	DROP COLUMN IF EXISTS recurrence_time,
+	DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS app.event_series;
@@ -0,0 +1,20 @@
+-- iCalendar feeds. SEQUENCE lets calendar apps know an event was updated
+-- and the tokens give each user a private feed URL.
+
+ALTER TABLE app.events
+	ADD COLUMN sequence		INTEGER		NOT NULL	DEFAULT 0;
+
+CREATE TABLE app.calendar_tokens (
+	id				BIGSERIAL		PRIMARY KEY,
+	user_id			BIGINT			NOT NULL UNIQUE references app.users(id),
+	the_value		varchar(64)		NOT NULL UNIQUE,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.calendar_tokens;
+
+ALTER TABLE app.events
+	DROP COLUMN IF EXISTS sequence;
@@ -0,0 +1,14 @@
+-- Once an event is full, new "yes" RSVPs wait in line for a spot.
+
+ALTER TABLE app.rsvps
+	ADD COLUMN waitlist_position	INTEGER;
+
+CREATE INDEX rsvps_waitlist_idx ON app.rsvps (event_id, waitlist_position)
+	WHERE waitlist_position IS NOT NULL;
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS app.rsvps_waitlist_idx;
+
+ALTER TABLE app.rsvps
+	DROP COLUMN IF EXISTS waitlist_position;
@@ -0,0 +1,10 @@
+-- Attendance check-in writes rsvps.actual. People who show up without an RSVP
+-- get one created at the door and are flagged as walk-ins.
+
+ALTER TABLE app.rsvps
+	ADD COLUMN walk_in		BOOLEAN		NOT NULL	DEFAULT false;
+
+---- create above / drop below ----
+
+ALTER TABLE app.rsvps
+	DROP COLUMN IF EXISTS walk_in;
@@ -0,0 +1,26 @@
+-- Event lifecycle. Events start as drafts and only show up publicly once
+-- published, either right away or at a scheduled time. Events that already
+-- exist are published.
+
+CREATE TYPE event_status AS ENUM ('draft', 'published', 'cancelled', 'postponed');
+
+ALTER TABLE app.events
+	ADD COLUMN status			event_status	NOT NULL	DEFAULT 'published',
+	ADD COLUMN publish_time		timestamp,
+	ADD COLUMN status_reason	TEXT			NOT NULL	DEFAULT '';
+
+ALTER TABLE app.events
+	ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX events_publish_idx ON app.events (publish_time) WHERE status = 'draft';
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS app.events_publish_idx;
+
+ALTER TABLE app.events
+	DROP COLUMN IF EXISTS status,
+	DROP COLUMN IF EXISTS publish_time,
+	DROP COLUMN IF EXISTS status_reason;
+
+DROP TYPE IF EXISTS event_status;
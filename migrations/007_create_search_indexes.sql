@@ -0,0 +1,18 @@
+-- Full-text search of events. The expressions have to match the ones used by
+-- db.SearchEvents for the indexes to be used.
+
+CREATE INDEX events_search_idx ON app.events USING GIN ((
+	setweight(to_tsvector('english', name), 'A') ||
+	setweight(to_tsvector('english', summary), 'B') ||
+	setweight(to_tsvector('english', description), 'C')
+));
+
+CREATE INDEX groups_search_idx ON app.groups USING GIN (to_tsvector('english', name));
+
+CREATE INDEX events_start_time_idx ON app.events (start_time);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS app.events_start_time_idx;
+DROP INDEX IF EXISTS app.groups_search_idx;
+DROP INDEX IF EXISTS app.events_search_idx;
@@ -0,0 +1,12 @@
+-- "Near me" searches. Events and groups are located by the city of their
+-- venue or group, so the spatial index is on cities.
+
+CREATE INDEX cities_location_idx ON app.cities USING GIST (location);
+
+ALTER TABLE app.users ADD COLUMN city_id INTEGER references app.cities(id);
+
+---- create above / drop below ----
+
+ALTER TABLE app.users DROP COLUMN IF EXISTS city_id;
+
+DROP INDEX IF EXISTS app.cities_location_idx;
@@ -0,0 +1,26 @@
+-- Threaded comments on events. Replies keep the root comment of their thread
+-- in root_id so that everyone in a thread can be found with one query.
+
+CREATE TABLE app.comments (
+	id				BIGSERIAL		PRIMARY KEY,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	user_id			BIGINT			NOT NULL references app.users(id),
+	parent_id		BIGINT			references app.comments(id) ON DELETE CASCADE,
+	root_id			BIGINT			references app.comments(id) ON DELETE CASCADE,
+	body			TEXT			NOT NULL,
+	is_pinned		boolean			NOT NULL	DEFAULT false,
+	is_hidden		boolean			NOT NULL	DEFAULT false,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX comments_event_idx ON app.comments (event_id, created_time);
+CREATE INDEX comments_root_idx ON app.comments (root_id);
+
+ALTER TABLE app.events ADD COLUMN comments_locked boolean NOT NULL DEFAULT false;
+
+---- create above / drop below ----
+
+ALTER TABLE app.events DROP COLUMN IF EXISTS comments_locked;
+
+DROP TABLE IF EXISTS app.comments;
@@ -0,0 +1,25 @@
+-- Each event keeps the IANA timezone it takes place in. start_time and
+-- end_time stay timestamps in UTC. Existing events get the timezone of their
+-- series, then of their venue's city, then of their group's city.
+
+ALTER TABLE app.events ADD COLUMN timezone varchar(40) NOT NULL DEFAULT 'UTC';
+
+UPDATE app.events e SET timezone=s.timezone
+	FROM app.event_series s
+	WHERE s.id=e.series_id;
+
+UPDATE app.events e SET timezone=c.timezone
+	FROM app.venues v
+	JOIN app.cities c ON c.id=v.city_id
+	WHERE e.series_id IS NULL AND v.id=e.venue_id AND c.timezone IS NOT NULL;
+
+UPDATE app.events e SET timezone=c.timezone
+	FROM app.groups g
+	JOIN app.cities c ON c.id=g.city_id
+	WHERE e.series_id IS NULL AND e.venue_id IS NULL AND g.id=e.group_id AND c.timezone IS NOT NULL;
+
+ALTER TABLE app.events ALTER COLUMN timezone DROP DEFAULT;
+
+---- create above / drop below ----
+
+ALTER TABLE app.events DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,12 @@
+-- Hybrid events have both a venue and an online link. attendee_limit is then
+-- the capacity of the in-person track and online_limit the one of the online
+-- track, 0 meaning there's no limit. RSVPs pick a track with their intent,
+-- 'in-person' or 'online'.
+
+ALTER TABLE app.events ADD COLUMN online_limit int NOT NULL DEFAULT 0;
+ALTER TABLE app.event_series ADD COLUMN online_limit int NOT NULL DEFAULT 0;
+
+---- create above / drop below ----
+
+ALTER TABLE app.event_series DROP COLUMN IF EXISTS online_limit;
+ALTER TABLE app.events DROP COLUMN IF EXISTS online_limit;
@@ -0,0 +1,38 @@
+-- Registration questions asked when RSVPing to an event, such as dietary
+-- needs. Answers are kept against the RSVP they were given with, a list of
+-- values so that multiple choice questions fit too.
+
+CREATE TYPE question_kind AS ENUM ('text', 'single', 'multi', 'checkbox');
+
+CREATE TABLE app.questions (
+	id				BIGSERIAL		PRIMARY KEY,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	label			varchar(200)	NOT NULL,
+	kind			question_kind	NOT NULL,
+	choices			TEXT[]			NOT NULL	DEFAULT '{}',
+	is_required		boolean			NOT NULL	DEFAULT false,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX questions_event_idx ON app.questions (event_id, id);
+
+CREATE TABLE app.answers (
+	event_id		BIGINT			NOT NULL,
+	user_id			BIGINT			NOT NULL,
+	question_id		BIGINT			NOT NULL references app.questions(id) ON DELETE CASCADE,
+	value			TEXT[]			NOT NULL,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+
+	CONSTRAINT answers_pk PRIMARY KEY (question_id, user_id),
+	CONSTRAINT answers_rsvp_fk FOREIGN KEY (event_id, user_id) references app.rsvps(event_id, user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX answers_event_idx ON app.answers (event_id, user_id);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.answers;
+DROP TABLE IF EXISTS app.questions;
+DROP TYPE IF EXISTS question_kind;
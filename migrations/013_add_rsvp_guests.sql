@@ -0,0 +1,17 @@
+-- Members can bring up to guest_limit guests to an event. Guests take a spot
+-- each, so an RSVP with guests needs 1 + guests spots to be confirmed. Guest
+-- names are optional.
+
+ALTER TABLE app.events ADD COLUMN guest_limit int NOT NULL DEFAULT 0;
+ALTER TABLE app.event_series ADD COLUMN guest_limit int NOT NULL DEFAULT 0;
+
+ALTER TABLE app.rsvps ADD COLUMN guests int NOT NULL DEFAULT 0;
+ALTER TABLE app.rsvps ADD COLUMN guest_names TEXT[] NOT NULL DEFAULT '{}';
+
+---- create above / drop below ----
+
+ALTER TABLE app.rsvps DROP COLUMN IF EXISTS guest_names;
+ALTER TABLE app.rsvps DROP COLUMN IF EXISTS guests;
+
+ALTER TABLE app.event_series DROP COLUMN IF EXISTS guest_limit;
+ALTER TABLE app.events DROP COLUMN IF EXISTS guest_limit;
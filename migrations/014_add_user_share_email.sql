@@ -0,0 +1,8 @@
+-- Members choose whether hosts of the events they RSVP to can see their email
+-- address, for example in attendee exports. It's off until they opt in.
+
+ALTER TABLE app.users ADD COLUMN share_email boolean NOT NULL DEFAULT false;
+
+---- create above / drop below ----
+
+ALTER TABLE app.users DROP COLUMN IF EXISTS share_email;
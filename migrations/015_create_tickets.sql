@@ -0,0 +1,46 @@
+-- Ticket types of an event, free or paid, and the orders people place for
+-- them when they RSVP. An order belongs to the RSVP with the same event and
+-- user, and covers the member and their guests. Prices are in the smallest
+-- unit of the currency, e.g. cents. Pending and confirmed orders hold their
+-- tickets, refunded and cancelled ones release them.
+
+CREATE TYPE order_status AS ENUM ('pending', 'confirmed', 'refunded', 'cancelled');
+
+CREATE TABLE app.ticket_types (
+	id				BIGSERIAL		PRIMARY KEY,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	name			varchar(100)	NOT NULL,
+	price			int				NOT NULL	DEFAULT 0,
+	currency		char(3)			NOT NULL	DEFAULT 'USD',
+	quantity		int				NOT NULL	DEFAULT 0,
+	sales_start		timestamp,
+	sales_end		timestamp,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ticket_types_event_idx ON app.ticket_types (event_id, id);
+
+CREATE TABLE app.orders (
+	id				BIGSERIAL		PRIMARY KEY,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	user_id			BIGINT			NOT NULL references app.users(id),
+	ticket_type_id	BIGINT			NOT NULL references app.ticket_types(id),
+	quantity		int				NOT NULL,
+	amount			int				NOT NULL,
+	currency		char(3)			NOT NULL,
+	status			order_status	NOT NULL	DEFAULT 'pending',
+	provider		varchar(40)		NOT NULL	DEFAULT '',
+	provider_ref	varchar(200)	NOT NULL	DEFAULT '',
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX orders_rsvp_idx ON app.orders (event_id, user_id);
+CREATE INDEX orders_ticket_type_idx ON app.orders (ticket_type_id, status);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.orders;
+DROP TABLE IF EXISTS app.ticket_types;
+DROP TYPE IF EXISTS order_status;
@@ -0,0 +1,20 @@
+-- Feedback attendees give once an event is over: a rating from 1 to 5 and
+-- optional comments. It's kept against the RSVP it was given with, one per
+-- attendee, and never shown with who gave it.
+
+CREATE TABLE app.feedback (
+	id				BIGSERIAL		NOT NULL,
+	event_id		BIGINT			NOT NULL,
+	user_id			BIGINT			NOT NULL,
+	rating			smallint		NOT NULL	CHECK (rating BETWEEN 1 AND 5),
+	comments		TEXT			NOT NULL	DEFAULT '',
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+
+	CONSTRAINT feedback_pk PRIMARY KEY (event_id, user_id),
+	CONSTRAINT feedback_rsvp_fk FOREIGN KEY (event_id, user_id) references app.rsvps(event_id, user_id) ON DELETE CASCADE
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.feedback;
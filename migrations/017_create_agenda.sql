@@ -0,0 +1,35 @@
+-- The agenda of an event: its sessions, such as talks, in the order hosts
+-- chose, and the speakers of each session. Speakers can be linked to the
+-- EventHunt user they are, otherwise only their name is kept.
+
+CREATE TABLE app.agenda_sessions (
+	id				BIGSERIAL		PRIMARY KEY,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	title			varchar(200)	NOT NULL,
+	abstract		TEXT			NOT NULL	DEFAULT '',
+	start_time		timestamp		NOT NULL,
+	end_time		timestamp		NOT NULL,
+	room			varchar(100)	NOT NULL	DEFAULT '',
+	position		int				NOT NULL,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX agenda_sessions_event_idx ON app.agenda_sessions (event_id, position);
+
+CREATE TABLE app.speakers (
+	id				BIGSERIAL		PRIMARY KEY,
+	session_id		BIGINT			NOT NULL references app.agenda_sessions(id) ON DELETE CASCADE,
+	name			varchar(100)	NOT NULL,
+	user_id			BIGINT			references app.users(id) ON DELETE SET NULL,
+	position		int				NOT NULL,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+	updated_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX speakers_session_idx ON app.speakers (session_id, position);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.speakers;
+DROP TABLE IF EXISTS app.agenda_sessions;
@@ -0,0 +1,16 @@
+-- Events imported into a group from iCalendar files, by the UID they had in
+-- the file, so that importing the same file again skips them. Occurrences of
+-- recurring events are kept by UID and recurrence.
+
+CREATE TABLE app.event_imports (
+	group_id		BIGINT			NOT NULL references app.groups(id) ON DELETE CASCADE,
+	uid				varchar(512)	NOT NULL,
+	event_id		BIGINT			NOT NULL references app.events(id) ON DELETE CASCADE,
+	created_time	timestamp		NOT NULL	DEFAULT CURRENT_TIMESTAMP,
+
+	CONSTRAINT event_imports_pk PRIMARY KEY (group_id, uid)
+);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS app.event_imports;
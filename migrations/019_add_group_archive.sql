@@ -0,0 +1,8 @@
+-- Archived groups are hidden from group listings and can't schedule events,
+-- but keep their past events. They can be unarchived by their owner.
+
+ALTER TABLE app.groups ADD COLUMN archived_time timestamp;
+
+---- create above / drop below ----
+
+ALTER TABLE app.groups DROP COLUMN IF EXISTS archived_time;
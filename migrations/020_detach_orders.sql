@@ -0,0 +1,16 @@
+-- Orders are the financial record of ticket sales, so they're kept when the
+-- group of their event is deleted. Those orders are detached from the event
+-- and ticket type, which are deleted, and keep the user, the amount and the
+-- reference of the payment provider.
+
+ALTER TABLE app.orders
+	ALTER COLUMN event_id DROP NOT NULL,
+	ALTER COLUMN ticket_type_id DROP NOT NULL;
+
+---- create above / drop below ----
+
+DELETE FROM app.orders WHERE event_id IS NULL OR ticket_type_id IS NULL;
+
+ALTER TABLE app.orders
+	ALTER COLUMN event_id SET NOT NULL,
+	ALTER COLUMN ticket_type_id SET NOT NULL;
@@ -26,6 +26,11 @@ var validate *validator.Validate
 
 type app struct {
 	*framework.App
+	// Payments takes the payments for paid tickets. It's nil when no
+	// provider is set up, and then only free tickets are available.
+	Payments PaymentProvider
+	// TicketKey signs the QR tickets of RSVPs.
+	TicketKey []byte
 }
 
 func (a *app) Initialize(themeRoot, themeName string) {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * Lists the agenda of an event, with a form to add a session.
+ *
+ * Path: /events/{event-id}/agenda
+ */
+func (a *app) agendaGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the agenda of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	agenda, err := db.GetAgendaByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get agenda.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/agenda", w, r, map[string]interface{}{
+		"User":   u,
+		"Event":  e,
+		"Agenda": agenda,
+	})
+}
+
+/*
+ * Adds a session to the agenda of an event. Times are in the timezone of the
+ * event.
+ *
+ * Path: /events/{event-id}/agenda
+ */
+func (a *app) agendaPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	agendaURL := "/events/" + e.IDString() + "/agenda"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the agenda of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	startTime, err := time.ParseInLocation("2006-01-02T15:04", r.Form.Get("start-time"), e.Location())
+	endTime, endErr := time.ParseInLocation("2006-01-02T15:04", r.Form.Get("end-time"), e.Location())
+	if err != nil || endErr != nil {
+		err = errors.New("The times aren't valid.")
+	}
+
+	if err == nil {
+		_, err = db.NewAgendaSession(
+			e,
+			r.Form.Get("title"),
+			r.Form.Get("abstract"),
+			startTime,
+			endTime,
+			r.Form.Get("room"),
+			strings.Split(r.Form.Get("speakers"), "\n"),
+		)
+	}
+	if err != nil {
+
+		slog.Error("Failed to create agenda session.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to add the session. " + err.Error(),
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, agendaURL, http.StatusFound)
+}
+
+/*
+ * Removes a session from the agenda, or moves it up or down.
+ *
+ * Path: /events/{event-id}/agenda/{session-id}/{action}
+ */
+func (a *app) agendaSessionPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	agendaURL := "/events/" + e.IDString() + "/agenda"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the agenda of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(chi.URLParam(r, "session-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	s, err := db.GetAgendaSessionByID(a.DB, sessionID)
+	if err == nil && s.EventID != e.ID {
+		a.util404Get(w, r)
+		return
+	}
+	if err == nil {
+
+		switch chi.URLParam(r, "action") {
+		case "up":
+			err = db.MoveAgendaSession(e, s.ID, true)
+		case "down":
+			err = db.MoveAgendaSession(e, s.ID, false)
+		case "delete":
+			err = s.Delete()
+		}
+	}
+	if err != nil {
+
+		slog.Error("Failed to change agenda session.", "eventID", e.ID, "sessionID", sessionID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to change the agenda.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, agendaURL, http.StatusFound)
+}
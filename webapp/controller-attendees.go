@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * The form for exporting the attendees of an event.
+ *
+ * Path: /events/{event-id}/attendees
+ */
+func (a *app) attendeesGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermAttendees) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to export the attendees.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	renderPage(a, "events/attendees", w, r, map[string]interface{}{
+		"User":     u,
+		"Event":    e,
+		"Statuses": db.AttendeeStatuses,
+	})
+}
+
+/*
+ * Downloads the RSVPs of an event as CSV or JSON. The status query parameter,
+ * which can be repeated, filters them.
+ *
+ * Path: /events/{event-id}/attendees.{format}
+ */
+func (a *app) attendeesExportGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermAttendees) {
+		respondWithError(w, http.StatusForbidden, "You don't have permission to export the attendees.")
+		return
+	}
+
+	var statuses []string
+	for _, status := range r.URL.Query()["status"] {
+		if slices.Contains(db.AttendeeStatuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+
+	records, err := db.GetAttendeeRecords(a.DB, e.ID, statuses)
+	if err != nil {
+		slog.Error("Failed to get attendees.", "eventID", e.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to get the attendees.")
+		return
+	}
+
+	format := chi.URLParam(r, "format")
+	filename := "event-" + e.IDString() + "-attendees." + format
+
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if format == "json" {
+
+		// an empty list rather than null
+		if records == nil {
+			records = []*db.AttendeeRecord{}
+		}
+
+		respondWithJSON(w, http.StatusOK, records)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+	c := csv.NewWriter(w)
+	c.Write(db.AttendeeCSVHeader)
+
+	for _, record := range records {
+		c.Write(csvSafe(record.CSV()))
+	}
+
+	c.Flush()
+	if err := c.Error(); err != nil {
+		slog.Error("Failed to write attendees CSV.", "eventID", e.ID, "err", err)
+	}
+}
+
+/*
+ * csvSafe returns the cells of a CSV record so that spreadsheets don't run
+ * them as formulas. Cells starting with a formula character get a leading
+ * quote, which spreadsheets show as text.
+ */
+func csvSafe(record []string) []string {
+
+	safe := make([]string, len(record))
+
+	for i, cell := range record {
+
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+
+		safe[i] = cell
+	}
+
+	return safe
+}
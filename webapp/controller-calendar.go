@@ -0,0 +1,172 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * Serves a single event as an iCalendar file, for adding it to a calendar
+ * app.
+ *
+ * Path: /events/{event-id}.ics
+ */
+func (a *app) calendarEventICS(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareUser might give us a User
+	u, _ := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	// Same rule as middlewareGroup, private groups are for their owner only
+	if e.TheGroup.IsPrivate && (u == nil || e.TheGroup.UserID != u.ID) {
+		respondWithError(w, 403, "User does not have permission.")
+		return
+	}
+
+	if e.IsDraft() && (u == nil || !e.TheGroup.HasCreate(u.ID)) {
+		respondWithError(w, 404, "Event not found.")
+		return
+	}
+
+	c := newICalCalendar(e.Name)
+	c.addEvent(e)
+	c.write(w, "event-"+e.IDString()+".ics")
+}
+
+/*
+ * Serves the upcoming and recent events of a group as an iCalendar feed that
+ * calendar apps can subscribe to.
+ *
+ * Path: /groups/{group-id}/events.ics
+ */
+func (a *app) calendarGroupICS(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	upcoming, err := db.GetEventsByGroup(a.DB, g.ID, false, 200)
+	if err != nil {
+		slog.Error("Failed to get upcoming events for feed.", "groupID", g.ID, "err", err)
+		respondWithError(w, 500, "Failed to load events.")
+		return
+	}
+
+	past, err := db.GetEventsByGroup(a.DB, g.ID, true, 50)
+	if err != nil {
+		slog.Error("Failed to get past events for feed.", "groupID", g.ID, "err", err)
+		respondWithError(w, 500, "Failed to load events.")
+		return
+	}
+
+	c := newICalCalendar(g.Name)
+	for _, e := range append(upcoming, past...) {
+		c.addEvent(e)
+	}
+	c.write(w, "group-"+g.IDString()+".ics")
+}
+
+/*
+ * Shows the URL of the user's personal calendar feed.
+ *
+ * Path: /users/me/calendar
+ */
+func (a *app) calendarUserGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	var feedURL string
+
+	ct, err := db.GetCalendarTokenByUser(a.DB, u.ID)
+	if err == nil {
+		feedURL = baseURL() + "/users/me/calendar.ics?token=" + ct.Value
+	}
+
+	renderPage(a, "users/calendar", w, r, map[string]interface{}{
+		"User":    u,
+		"FeedURL": feedURL,
+	})
+}
+
+/*
+ * Creates the user's calendar feed URL, or replaces it so that the old one
+ * stops working.
+ *
+ * Path: /users/me/calendar
+ */
+func (a *app) calendarUserPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	_, err := db.NewCalendarToken(u)
+	if err != nil {
+
+		slog.Error("Failed to create calendar token.", "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to create your calendar URL.",
+		})
+	} else {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"Your calendar URL is ready. Any previous URL no longer works.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/users/me/calendar", http.StatusFound)
+}
+
+/*
+ * Serves the events a user said yes or maybe to as an iCalendar feed. Calendar
+ * apps don't have our cookies so the user is found by the token in the URL
+ * instead.
+ *
+ * Path: /users/me/calendar.ics?token={token}
+ */
+func (a *app) calendarUserICS(w http.ResponseWriter, r *http.Request) {
+
+	ct, err := db.GetCalendarTokenByValue(a.DB, r.URL.Query().Get("token"))
+	if err != nil {
+		respondWithError(w, 404, "Calendar not found.")
+		return
+	}
+
+	u, err := db.GetUserByID(a.DB, ct.UserID)
+	if err != nil {
+		slog.Error("Failed to get user for calendar feed.", "userID", ct.UserID, "err", err)
+		respondWithError(w, 500, "Failed to load calendar.")
+		return
+	}
+
+	events, err := db.GetEventsByRSVP(a.DB, u.ID, []db.RSVPStatus{db.RSVPYes, db.RSVPMaybe}, 500)
+	if err != nil {
+		slog.Error("Failed to get events for calendar feed.", "userID", u.ID, "err", err)
+		respondWithError(w, 500, "Failed to load calendar.")
+		return
+	}
+
+	// Calendar apps don't need events from long ago
+	since := time.Now().AddDate(0, -6, 0)
+
+	c := newICalCalendar(AppName + " - " + u.Username)
+	for _, e := range events {
+
+		if e.StartTime.Before(since) {
+			continue
+		}
+
+		c.addEvent(e)
+	}
+	c.write(w, "calendar-"+strconv.FormatUint(u.ID, 10)+".ics")
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * The check-in screen for hosts. Lists the RSVPs of an event, optionally
+ * filtered by a search, with the attendance figures so far.
+ *
+ * Path: /events/{event-id}/check-in
+ */
+func (a *app) checkinGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermCheckIn) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to check people in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	rsvps, err := e.RSVPs()
+	if err != nil {
+		slog.Error("Failed to get RSVPs for check-in.", "eventID", e.ID, "err", err)
+	}
+
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	if search != "" {
+
+		needle := strings.ToLower(search)
+		var matches []*db.RSVP
+
+		for _, rsvp := range rsvps {
+
+			name := rsvp.TheUser.Username + " " + rsvp.TheUser.FirstName + " " + rsvp.TheUser.LastName + " " + strings.Join(rsvp.GuestNames, " ")
+			if strings.Contains(strings.ToLower(name), needle) {
+				matches = append(matches, rsvp)
+			}
+		}
+
+		rsvps = matches
+	}
+
+	stats, err := db.GetAttendanceByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get attendance.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/check-in", w, r, map[string]interface{}{
+		"User":       u,
+		"Event":      e,
+		"RSVPs":      rsvps,
+		"Search":     search,
+		"Attendance": stats,
+	})
+}
+
+/*
+ * Marks a user as attended in-person, online, or as a no-show. An empty value
+ * clears it.
+ *
+ * Path: /events/{event-id}/check-in
+ */
+func (a *app) checkinPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermCheckIn) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to check people in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	checkinURL := "/events/" + e.IDString() + "/check-in?q=" + url.QueryEscape(r.Form.Get("q"))
+
+	userID, err := strconv.ParseUint(r.Form.Get("user-id"), 10, 64)
+	if err != nil {
+
+		slog.Error("User ID is not valid.", "user-id", r.Form.Get("user-id"))
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to check in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, checkinURL, http.StatusFound)
+		return
+	}
+
+	// Only people that RSVP'd can be marked here, walk-ins have their own form
+	if _, err := db.GetRSVP(a.DB, e.ID, userID); err != nil {
+
+		slog.Error("Failed to get RSVP for check-in.", "eventID", e.ID, "userID", userID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to check in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, checkinURL, http.StatusFound)
+		return
+	}
+
+	actual, ok := checkinActual(r.Form.Get("actual"))
+	if !ok {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Unknown attendance value.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, checkinURL, http.StatusFound)
+		return
+	}
+
+	err = db.CheckIn(e, userID, actual)
+	if err != nil {
+
+		slog.Error("Failed to check in.", "eventID", e.ID, "userID", userID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to check in.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, checkinURL, http.StatusFound)
+	return
+}
+
+/*
+ * Checks in a member that showed up without an RSVP, found by username or
+ * email address.
+ *
+ * Path: /events/{event-id}/check-in/walk-in
+ */
+func (a *app) checkinWalkInPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	checkinURL := "/events/" + e.IDString() + "/check-in"
+
+	if !e.Can(u.ID, db.EventPermCheckIn) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to check people in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	member := strings.TrimSpace(r.Form.Get("member"))
+
+	walkIn, err := db.GetUserByUsernameOrEmail(a.DB, member)
+	if err != nil {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"No member found with the username or email \"" + member + "\".",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, checkinURL, http.StatusFound)
+		return
+	}
+
+	actual, ok := checkinActual(r.Form.Get("actual"))
+	if !ok || actual == nil || !actual.IsAttending() {
+		inPerson := db.RSVPInPerson
+		actual = &inPerson
+	}
+
+	err = db.CheckIn(e, walkIn.ID, actual)
+	if err != nil {
+
+		slog.Error("Failed to check in walk-in.", "eventID", e.ID, "userID", walkIn.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to check in " + walkIn.Username + ".",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, checkinURL, http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		walkIn.Username + " has been checked in.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, checkinURL, http.StatusFound)
+	return
+}
+
+/*
+ * Attendance figures of every member over the past events of a group.
+ *
+ * Path: /groups/{group-id}/attendance
+ */
+func (a *app) groupsAttendanceGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !g.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to view attendance.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	members, err := db.GetAttendanceByGroup(a.DB, g.ID)
+	if err != nil {
+		slog.Error("Failed to get group attendance.", "groupID", g.ID, "err", err)
+	}
+
+	var total db.Attendance
+	for _, m := range members {
+		total.Expected += m.Expected
+		total.InPerson += m.InPerson
+		total.Online += m.Online
+		total.NoShows += m.NoShows
+		total.WalkIns += m.WalkIns
+	}
+
+	renderPage(a, "groups/attendance", w, r, map[string]interface{}{
+		"User":       u,
+		"Group":      g,
+		"Members":    members,
+		"Attendance": total,
+	})
+}
+
+/*
+ * checkinActual converts a check-in form value to an rsvps.actual value. An
+ * empty value is nil, for clearing it.
+ */
+func checkinActual(value string) (*db.RSVPStatus, bool) {
+
+	switch value {
+	case "":
+		return nil, true
+	case "in-person", "online":
+		actual := db.RSVPStatus(value)
+		return &actual, true
+	case "no-show":
+		actual := db.RSVPNo
+		return &actual, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * Posts a comment on an event, or a reply when parent-id is set. Members of
+ * the event's group can comment, unless the hosts locked the comments.
+ *
+ * Path: /events/{event-id}/comments
+ */
+func (a *app) commentsPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	eventURL := "/events/" + e.IDString()
+	isHost := e.TheGroup.HasCreate(u.ID)
+
+	if !isHost && (e.IsDraft() || !e.TheGroup.IsMember(u.ID)) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Only members of " + e.TheGroup.Name + " can comment.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	if e.CommentsLocked && !isHost {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Comments are locked for this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	body := strings.TrimSpace(r.Form.Get("body"))
+
+	var parent *db.Comment
+	if parentID := r.Form.Get("parent-id"); parentID != "" {
+
+		id, err := strconv.ParseUint(parentID, 10, 64)
+		if err == nil {
+			parent, err = db.GetCommentByID(a.DB, id)
+		}
+		if err != nil || parent.EventID != e.ID {
+
+			slog.Error("Parent comment is not valid.", "parent-id", parentID, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"The comment you replied to doesn't exist anymore.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, eventURL, http.StatusFound)
+			return
+		}
+	}
+
+	c, err := db.NewComment(u, e, parent, body)
+	if err != nil {
+
+		slog.Error("Failed to post comment.", "eventID", e.ID, "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to post your comment. It can't be empty or longer than 5,000 characters.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	if parent != nil {
+		a.notifyCommentReply(e, c)
+	}
+
+	http.Redirect(w, r, eventURL+"#comment-"+c.IDString(), http.StatusFound)
+}
+
+/*
+ * Lets hosts pin, hide or delete a comment. Deleting a comment deletes its
+ * replies too.
+ *
+ * Path: /events/{event-id}/comments/{comment-id}/{action}
+ */
+func (a *app) commentsModeratePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	eventURL := "/events/" + e.IDString()
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to moderate comments.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	commentID, err := strconv.ParseUint(chi.URLParam(r, "comment-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	c, err := db.GetCommentByID(a.DB, commentID)
+	if err != nil || c.EventID != e.ID {
+		respondWithError(w, 404, "Comment not found.")
+		return
+	}
+
+	action := chi.URLParam(r, "action")
+
+	switch action {
+	case "pin", "unpin":
+		err = c.Pin(action == "pin")
+	case "hide", "unhide":
+		err = c.Hide(action == "hide")
+	case "delete":
+		err = c.Delete()
+	}
+	if err != nil {
+
+		slog.Error("Failed to moderate comment.", "commentID", c.ID, "action", action, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to " + action + " the comment. " + err.Error(),
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL+"#comment-"+c.IDString(), http.StatusFound)
+		return
+	}
+
+	if action == "delete" {
+		http.Redirect(w, r, eventURL+"#comments", http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, eventURL+"#comment-"+c.IDString(), http.StatusFound)
+}
+
+/*
+ * Lets hosts lock the comments of an event, or unlock them.
+ *
+ * Path: /events/{event-id}/comments/{action:lock|unlock}
+ */
+func (a *app) commentsLockPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	eventURL := "/events/" + e.IDString()
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to moderate comments.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	err := e.LockComments(chi.URLParam(r, "action") == "lock")
+	if err != nil {
+
+		slog.Error("Failed to lock comments.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to update the comments.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, eventURL+"#comments", http.StatusFound)
+}
+
+/*
+ * notifyCommentReply emails everyone in the thread of a reply, except its
+ * author.
+ */
+func (a *app) notifyCommentReply(e *db.Event, c *db.Comment) {
+
+	participants, err := db.GetThreadParticipants(a.DB, c.ThreadID())
+	if err != nil {
+		slog.Error("Failed to get thread participants.", "commentID", c.ID, "err", err)
+		return
+	}
+
+	for _, participant := range participants {
+
+		if participant.ID == c.UserID {
+			continue
+		}
+
+		err := sendEmailCommentReply(participant, e, c)
+		if err != nil {
+			slog.Error("Failed to send comment reply email.", "commentID", c.ID, "userID", participant.ID, "err", err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * The form for duplicating an event.
+ *
+ * Path: /events/{event-id}/duplicate
+ */
+func (a *app) duplicateEventGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to duplicate this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if !a.checkNotArchived(w, r, e.TheGroup) {
+		return
+	}
+
+	renderPage(a, "events/duplicate", w, r, map[string]interface{}{
+		"User":  u,
+		"Event": e,
+	})
+}
+
+/*
+ * Duplicates an event into a new draft, shifted by the chosen amount, and
+ * opens it for editing.
+ *
+ * Path: /events/{event-id}/duplicate
+ */
+func (a *app) duplicateEventPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	duplicateURL := "/events/" + e.IDString() + "/duplicate"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to duplicate this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if !a.checkNotArchived(w, r, e.TheGroup) {
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	dup, err := duplicateEvent(u, e, duplicateShift(r))
+	if err != nil {
+
+		slog.Error("Failed to duplicate event.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to duplicate the event. " + err.Error(),
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, duplicateURL, http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"A draft copy was created for " + dup.LocalStart().Format("January 2, 2006") + ". Review it, then publish it.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+dup.IDString()+"/edit", http.StatusFound)
+}
+
+/*
+ * Lists the past events of a group so that several can be scheduled again at
+ * once.
+ *
+ * Path: /groups/{group-id}/duplicate
+ */
+func (a *app) duplicateGroupGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !g.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to schedule events for this group.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	if !a.checkNotArchived(w, r, g) {
+		return
+	}
+
+	renderPage(a, "groups/duplicate", w, r, map[string]interface{}{
+		"User":   u,
+		"Group":  g,
+		"Events": g.PastEvents(50),
+	})
+}
+
+/*
+ * Duplicates the selected past events of a group into drafts.
+ *
+ * Path: /groups/{group-id}/duplicate
+ */
+func (a *app) duplicateGroupPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	duplicateURL := "/groups/" + g.IDString() + "/duplicate"
+
+	if !g.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to schedule events for this group.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	if !a.checkNotArchived(w, r, g) {
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	shift := duplicateShift(r)
+	created := 0
+
+	for _, value := range r.Form["event-id"] {
+
+		eventID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			slog.Error("Event ID is not valid.", "event-id", value)
+			continue
+		}
+
+		e, err := db.GetEventByID(a.DB, eventID)
+		if err != nil || e.GroupID != g.ID {
+			slog.Error("Failed to get event to duplicate.", "eventID", eventID, "groupID", g.ID, "err", err)
+			continue
+		}
+
+		_, err = duplicateEvent(u, e, shift)
+		if err != nil {
+
+			slog.Error("Failed to duplicate event.", "eventID", e.ID, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Failed to duplicate " + e.Name + ". " + err.Error(),
+			})
+			continue
+		}
+
+		created++
+	}
+
+	if created == 0 {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"No events were duplicated.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, duplicateURL, http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		strconv.Itoa(created) + " draft events were created. Review them, then publish them.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+}
+
+/*
+ * duplicateEvent copies an event to the first upcoming date reached by the
+ * shift. The shift happens in the event's timezone so that the copy starts at
+ * the same local time even across a DST change.
+ */
+func duplicateEvent(u *db.User, e *db.Event, shift db.Shift) (*db.Event, error) {
+
+	start, err := shift.ShiftToFuture(e.LocalStart(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Duplicate(u, start)
+}
+
+/*
+ * duplicateShift reads the shift-count and shift-unit form fields, one week
+ * when missing.
+ */
+func duplicateShift(r *http.Request) db.Shift {
+
+	shift := db.Shift{Count: 1, Unit: "week"}
+
+	if count, err := strconv.Atoi(r.Form.Get("shift-count")); err == nil {
+		shift.Count = count
+	}
+
+	if unit := r.Form.Get("shift-unit"); unit != "" {
+		shift.Unit = unit
+	}
+
+	return shift
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/eventhunt-org/webapp/framework"
@@ -12,22 +15,149 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+/*
+ * Searches upcoming events by keyword, dates, location and format.
+ *
+ * Path: /events?q={query}&from={date}&to={date}&country={code}&region={code}&city={id}&format={format}&mine=1&page={page}
+ */
 func (a *app) eventsIndex(w http.ResponseWriter, r *http.Request) {
 
 	// middlewareUser might give us a User
 	u, _ := r.Context().Value("user").(*db.User)
 
-	events, err := db.GetEvents(a.DB, 25)
+	// Everything about the search is in the query string so that it can be
+	// shared and bookmarked.
+	params := r.URL.Query()
+
+	search := &db.EventSearch{
+		Query:    strings.TrimSpace(params.Get("q")),
+		Country:  strings.ToUpper(params.Get("country")),
+		Region:   params.Get("region"),
+		Format:   params.Get("format"),
+		MineOnly: params.Get("mine") == "1" && u != nil,
+		Page:     1,
+	}
+
+	if u != nil {
+		search.UserID = u.ID
+	}
+
+	if page, err := strconv.Atoi(params.Get("page")); err == nil && page > 0 {
+		search.Page = page
+	}
+
+	if cityID, err := strconv.ParseUint(params.Get("city"), 10, 64); err == nil {
+		search.CityID = cityID
+	}
+
+	// dates are whole days, the end date included
+	if from, err := time.Parse(time.DateOnly, params.Get("from")); err == nil {
+		search.From = &from
+	}
+	if to, err := time.Parse(time.DateOnly, params.Get("to")); err == nil {
+		to = to.AddDate(0, 0, 1)
+		search.To = &to
+	}
+
+	result, err := db.SearchEvents(a.DB, search)
 	if err != nil {
-		slog.Error("Failed to get a list of events.", "err", err)
+		slog.Error("Failed to search events.", "query", r.URL.RawQuery, "err", err)
+		result = &db.EventSearchResult{Page: 1}
+	}
+
+	var prevURL, nextURL string
+	if result.Page > 1 {
+		prevURL = searchURL(params, "page", strconv.Itoa(result.Page-1))
+	}
+	if result.Page < result.Pages {
+		nextURL = searchURL(params, "page", strconv.Itoa(result.Page+1))
 	}
 
 	renderPage(a, "events/index", w, r, map[string]interface{}{
-		"User":   u,
-		"Events": events,
+		"User":      u,
+		"Events":    result.Events,
+		"Search":    params,
+		"Result":    result,
+		"Formats":   searchLinks(params, "format", result.Formats),
+		"Countries": searchLinks(params, "country", result.Countries),
+		"Regions":   searchLinks(params, "region", result.Regions),
+		"Cities":    searchLinks(params, "city", result.Cities),
+		"PrevURL":   prevURL,
+		"NextURL":   nextURL,
 	})
 }
 
+/*
+ * searchLink is a facet value of the event search, linking to the search
+ * with that filter applied. Following an active link removes the filter.
+ */
+type searchLink struct {
+	Label  string
+	Count  int
+	URL    string
+	Active bool
+}
+
+/*
+ * searchLinks turns the facets of a filter into links.
+ */
+func searchLinks(params url.Values, key string, facets []db.Facet) []searchLink {
+
+	var links []searchLink
+
+	for _, facet := range facets {
+
+		link := searchLink{
+			Label:  facet.Label,
+			Count:  facet.Count,
+			Active: strings.EqualFold(params.Get(key), facet.Value),
+		}
+
+		if link.Active {
+			link.URL = searchURL(params, key, "")
+		} else {
+			link.URL = searchURL(params, key, facet.Value)
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+/*
+ * searchURL returns the URL of the event search with one parameter changed.
+ * An empty value removes the parameter. Any change other than the page goes
+ * back to the first page, and a broader location clears the narrower ones.
+ */
+func searchURL(params url.Values, key, value string) string {
+
+	values := url.Values{}
+	for k, v := range params {
+		values[k] = append([]string(nil), v...)
+	}
+
+	if key != "page" {
+		values.Del("page")
+	}
+
+	switch key {
+	case "country":
+		values.Del("region")
+		values.Del("city")
+	case "region":
+		values.Del("city")
+	}
+
+	if value == "" {
+		values.Del(key)
+	} else {
+		values.Set(key, value)
+	}
+
+	return "/events?" + values.Encode()
+}
+
 /*
  * View a single event.
  */
@@ -67,17 +197,344 @@ func (a *app) eventsSingle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Drafts are only for the hosts to see
+	if e.IsDraft() && (u == nil || !e.TheGroup.HasCreate(u.ID)) {
+		a.util404Get(w, r)
+		return
+	}
+
+	comments, err := e.Comments()
+	if err != nil {
+		slog.Error("Failed to get comments.", "eventID", e.ID, "err", err)
+	}
+
+	isHost := u != nil && e.TheGroup.HasCreate(u.ID)
+	canComment := isHost || (u != nil && !e.CommentsLocked && e.TheGroup.IsMember(u.ID))
+
+	// event staff get some of the host buttons
+	var canCheckIn, canVenue, canMessage, canAnswers, canAttendees bool
+	var userID uint64
+	if u != nil {
+		userID = u.ID
+		canCheckIn = e.Can(u.ID, db.EventPermCheckIn)
+		canVenue = e.Can(u.ID, db.EventPermVenue)
+		canMessage = e.Can(u.ID, db.EventPermMessage)
+		canAnswers = e.Can(u.ID, db.EventPermAnswers)
+		canAttendees = e.Can(u.ID, db.EventPermAttendees)
+	}
+
+	// attendees are asked for feedback once the event is over
+	feedbackOpen := e.FeedbackOpen(time.Now())
+	canFeedback := false
+	if u != nil && feedbackOpen {
+		rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID)
+		canFeedback = err == nil && rsvp.CanGiveFeedback()
+	}
+
+	// confirmed attendees get a ticket to show at the door
+	ticketCode := ""
+	if u != nil {
+		if rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID); err == nil && hasTicket(rsvp) && !e.IsCancelled() {
+			ticketCode = a.ticketCode(e.ID, u.ID)
+		}
+	}
+
 	renderPage(a, "events/single", w, r, map[string]interface{}{
-		"User":  u,
-		"Event": e,
+		"User":         u,
+		"Event":        e,
+		"Comments":     comments,
+		"IsHost":       isHost,
+		"CanComment":   canComment,
+		"CanCheckIn":   canCheckIn,
+		"CanVenue":     canVenue,
+		"CanMessage":   canMessage,
+		"CanAnswers":   canAnswers,
+		"CanAttendees": canAttendees,
+		"FeedbackOpen": feedbackOpen,
+		"CanFeedback":  canFeedback,
+		"OnlineLink":   e.CanSeeOnlineLink(userID),
+		"TicketCode":   ticketCode,
+		"Meta":         eventMeta(e),
 	})
 }
 
-func (this *app) eventsEditGet(w http.ResponseWriter, r *http.Request) {
+/*
+ * Page to edit an existing event.
+ *
+ * Path: /events/{event-id}/edit
+ */
+func (a *app) eventsEditGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	// Only the owner of the group can add/edit an event
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to edit this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	var s *db.Series
+	if e.SeriesID != nil {
+
+		var err error
+		s, err = db.GetSeriesByID(a.DB, *e.SeriesID)
+		if err != nil {
+			slog.Error("Failed to load series.", "seriesID", *e.SeriesID, "err", err)
+		}
+	}
+
+	timezones, err := db.GetTimezoneNames(a.DB)
+	if err != nil {
+		slog.Error("Failed to get timezones.", "err", err)
+	}
+
+	renderPage(a, "events/edit", w, r, map[string]interface{}{
+		"User":      u,
+		"Event":     e,
+		"Series":    s,
+		"Timezones": timezones,
+	})
 }
 
+/*
+ * Processing of the edit event page. If the time of the event changes, every
+ * user that RSVP'd is notified by email.
+ *
+ * Path: /events/{event-id}/edit
+ */
 func (a *app) eventsEditPost(w http.ResponseWriter, r *http.Request) {
 
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	// Only the owner of the group can add/edit an event
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to edit this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	editURL := "/events/" + e.IDString() + "/edit"
+	timezone := r.Form.Get("timezone")
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+
+		slog.Error("Timezone is not parsable.", "timezone", timezone)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Timezone was not valid.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	startTime, err := time.ParseInLocation("2006-01-02T15:04", r.Form.Get("start-time"), loc)
+	if err != nil {
+
+		slog.Error("Start time is not parsable.", "start-time", r.Form.Get("start-time"))
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Start time was not valid.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	endTime, err := time.ParseInLocation("2006-01-02T15:04", r.Form.Get("end-time"), loc)
+	if err != nil {
+
+		slog.Error("End time is not parsable.", "end-time", r.Form.Get("end-time"))
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"End time was not valid.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	attendeeLimit := 0
+	if limit := r.Form.Get("attendee-limit"); limit != "" {
+
+		attendeeLimit, err = strconv.Atoi(limit)
+		if err != nil || attendeeLimit < 0 {
+
+			slog.Error("Attendee limit is not valid.", "attendee-limit", limit)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Attendee limit was not valid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, editURL, http.StatusFound)
+			return
+		}
+	}
+
+	onlineLimit := 0
+	if limit := r.Form.Get("online-limit"); limit != "" {
+
+		onlineLimit, err = strconv.Atoi(limit)
+		if err != nil || onlineLimit < 0 {
+
+			slog.Error("Online limit is not valid.", "online-limit", limit)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Online attendee limit was not valid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, editURL, http.StatusFound)
+			return
+		}
+	}
+
+	guestLimit := 0
+	if limit := r.Form.Get("guest-limit"); limit != "" {
+
+		guestLimit, err = strconv.Atoi(limit)
+		if err != nil || guestLimit < 0 {
+
+			slog.Error("Guest limit is not valid.", "guest-limit", limit)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Guests per RSVP was not valid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, editURL, http.StatusFound)
+			return
+		}
+	}
+
+	timeChanged := !e.StartTime.Equal(startTime.UTC()) || !e.EndTime.Equal(endTime.UTC())
+
+	e.Name = r.Form.Get("event-name")
+	e.StartTime = startTime.UTC()
+	e.EndTime = endTime.UTC()
+	e.Timezone = timezone
+	e.Summary = r.Form.Get("event-summary")
+	e.Description = r.Form.Get("event-description")
+	e.WebURL = r.Form.Get("event-url")
+	e.AttendeeLimit = attendeeLimit
+	e.OnlineLimit = onlineLimit
+	e.GuestLimit = guestLimit
+
+	scope := r.Form.Get("scope")
+	if e.SeriesID == nil || e.RecurrenceTime == nil {
+		scope = "this"
+	}
+
+	var series *db.Series
+
+	switch scope {
+	case "all", "following":
+		series, err = a.saveSeriesEdit(e, scope, startTime, endTime, r.Form.Get("rrule"))
+	default:
+		// An occurrence edited on its own is no longer updated by its series.
+		e.IsOverride = e.SeriesID != nil
+		err = e.Save()
+	}
+	if err != nil {
+
+		slog.Error("Failed to save event.", "id", e.ID, "err", err)
+
+		msg := "Failed to save event. Please check the values provided."
+		if errors.Is(err, db.ErrAgendaOutsideEvent) {
+			msg = db.ErrAgendaOutsideEvent.Error()
+		}
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			msg,
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	// A higher attendee limit, of either track, can make room for people on
+	// the waitlist
+	a.promoteWaitlist(e)
+	if series != nil {
+
+		events, err := series.Events()
+		if err != nil {
+			slog.Error("Failed to get events of series.", "seriesID", series.ID, "err", err)
+		}
+
+		for _, occurrence := range events {
+			if occurrence.ID != e.ID && !occurrence.IsOverride && occurrence.StartTime.After(time.Now()) {
+				a.promoteWaitlist(occurrence)
+			}
+		}
+	}
+
+	if timeChanged && series != nil {
+
+		events, err := series.Events()
+		if err != nil {
+			slog.Error("Failed to get events of series.", "seriesID", series.ID, "err", err)
+		}
+
+		for _, occurrence := range events {
+			if occurrence.IsOverride || occurrence.StartTime.Before(time.Now()) {
+				continue
+			}
+			a.notifyEventChanged(occurrence, u, "time")
+		}
+	} else if timeChanged {
+		a.notifyEventChanged(e, u, "time")
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"Event has been updated.",
+	})
+
+	// A new repeat rule can remove the occurrence that was being edited.
+	if _, err := db.GetEventByID(a.DB, e.ID); err != nil {
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+strconv.FormatUint(e.GroupID, 10), http.StatusFound)
+		return
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+	return
 }
 
 /*
@@ -149,9 +606,26 @@ func (a *app) eventsNew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !a.checkNotArchived(w, r, g) {
+		return
+	}
+
+	// Until a venue is picked, events are in the timezone of their group
+	timezone, err := db.GetCityTimezone(a.DB, g.CityID)
+	if err != nil {
+		slog.Error("Failed to get timezone of group city.", "groupID", g.ID, "err", err)
+	}
+
+	timezones, err := db.GetTimezoneNames(a.DB)
+	if err != nil {
+		slog.Error("Failed to get timezones.", "err", err)
+	}
+
 	renderPage(a, "events/new", w, r, map[string]interface{}{
-		"User":  u,
-		"Group": g,
+		"User":      u,
+		"Group":     g,
+		"Timezone":  timezone,
+		"Timezones": timezones,
 	})
 }
 
@@ -180,6 +654,10 @@ func (a *app) eventsNewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !a.checkNotArchived(w, r, g) {
+		return
+	}
+
 	r.ParseForm()
 	defer r.Body.Close()
 
@@ -229,7 +707,72 @@ func (a *app) eventsNewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	e, err := db.NewEvent(u, g.ID, name, startTime, endTime, summary)
+	rule, err := seriesRuleFromForm(r.Form, startTime)
+	if err != nil {
+
+		slog.Error("Repeat options are not valid.", "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Repeat options were not valid.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString()+"/schedule", http.StatusFound)
+		return
+	}
+
+	// A recurring event creates a series which in turn creates the events.
+	// The venue page is then shown for the first one.
+	if rule != "" {
+
+		exDates, err := parseSkipDates(r.Form.Get("skip-dates"), startTime)
+		if err != nil {
+
+			slog.Error("Skip dates are not valid.", "skip-dates", r.Form.Get("skip-dates"))
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Dates to skip were not valid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/groups/"+g.IDString()+"/schedule", http.StatusFound)
+			return
+		}
+
+		s, err := db.NewSeries(u, g.ID, name, startTime, endTime, timezone, rule, exDates, summary)
+		if err != nil {
+
+			slog.Error("Failed to create series.", "msg", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Failed to create recurring event.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/groups/"+g.IDString()+"/schedule", http.StatusFound)
+			return
+		}
+
+		events, err := s.Events()
+		if err != nil || len(events) == 0 {
+
+			slog.Error("Recurring event has no occurrences.", "seriesID", s.ID, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashWarn,
+				"The recurring event was created but has no upcoming dates.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+			return
+		}
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+events[0].IDString()+"/new-venue", http.StatusFound)
+		return
+	}
+
+	e, err := db.NewEvent(u, g.ID, name, startTime, endTime, timezone, summary)
 	if err != nil {
 
 		slog.Error("Failed to create event.", "msg", err)
@@ -247,3 +790,239 @@ func (a *app) eventsNewPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/events/"+e.IDString()+"/new-venue", http.StatusFound)
 	return
 }
+
+/*
+ * notifyEventChanged emails everyone who RSVP'd to an event that something
+ * important about it, such as the time or the venue, has changed. The user
+ * making the change isn't notified.
+ */
+func (a *app) notifyEventChanged(e *db.Event, editor *db.User, change string) {
+
+	rsvps, err := e.RSVPs()
+	if err != nil {
+		slog.Error("Failed to get RSVPs for event change notification.", "eventID", e.ID, "err", err)
+		return
+	}
+
+	for _, rsvp := range rsvps {
+
+		if rsvp.UserID == editor.ID {
+			continue
+		}
+
+		err := sendEmailEventChanged(rsvp.TheUser, e, change)
+		if err != nil {
+			slog.Error("Failed to send event change email.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+		}
+	}
+}
+
+/*
+ * Publishes a draft or postponed event, right away or at the provided time.
+ * Publishing an occurrence of a recurring series publishes the series.
+ *
+ * Path: /events/{event-id}/publish
+ */
+func (a *app) eventsPublishPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	eventURL := "/events/" + e.IDString()
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to publish this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	if e.IsCancelled() {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			"Cancelled events can't be published again.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	var publishTime *time.Time
+
+	if at := r.Form.Get("publish-time"); at != "" {
+
+		loc, err := time.LoadLocation(r.Form.Get("timezone"))
+		if err != nil {
+			loc = time.UTC
+		}
+
+		t, err := time.ParseInLocation("2006-01-02T15:04", at, loc)
+		if err != nil {
+
+			slog.Error("Publish time is not parsable.", "publish-time", at)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Publish time was not valid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, eventURL, http.StatusFound)
+			return
+		}
+
+		publishTime = &t
+	}
+
+	wasPostponed := e.IsPostponed()
+
+	err := e.Publish(publishTime)
+	if err == nil && e.SeriesID != nil && !e.IsDraft() {
+
+		var s *db.Series
+		s, err = db.GetSeriesByID(a.DB, *e.SeriesID)
+		if err == nil {
+			err = s.Publish()
+		}
+	}
+	if err != nil {
+
+		slog.Error("Failed to publish event.", "id", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to publish event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	// a postponed event is back on, people that RSVP'd need the new details
+	if wasPostponed {
+		a.notifyEventChanged(e, u, "status")
+	}
+
+	if e.IsDraft() {
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"The event will be published on " + e.PublishTime.In(publishTime.Location()).Format("January 2, 2006 3:04p.m.") + ".",
+		})
+	} else {
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"The event is now published.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, eventURL, http.StatusFound)
+	return
+}
+
+/*
+ * Cancels or postpones an event and lets everyone that RSVP'd know why.
+ *
+ * Path: /events/{event-id}/{cancel|postpone}
+ */
+func (a *app) eventsStatusPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	eventURL := "/events/" + e.IDString()
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to change this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	reason := r.Form.Get("reason")
+	action := chi.URLParam(r, "action")
+
+	var err error
+
+	if action == "cancel" {
+		err = e.Cancel(reason)
+	} else {
+		err = e.Postpone(reason)
+	}
+	if err != nil {
+
+		slog.Error("Failed to change event status.", "id", e.ID, "action", action, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to update the event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, eventURL, http.StatusFound)
+		return
+	}
+
+	// attendees are refunded before they're told about the cancellation
+	if action == "cancel" && a.releaseEventOrders(e) > 0 {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			"Some tickets couldn't be refunded. Refund them from the orders page.",
+		})
+	}
+
+	rsvps, err := e.RSVPs()
+	if err != nil {
+		slog.Error("Failed to get RSVPs for status notification.", "eventID", e.ID, "err", err)
+	}
+
+	for _, rsvp := range rsvps {
+
+		if rsvp.UserID == u.ID {
+			continue
+		}
+
+		if action == "cancel" {
+			err = sendEmailEventCancelled(rsvp.TheUser, e, reason)
+		} else {
+			err = sendEmailEventPostponed(rsvp.TheUser, e, reason)
+		}
+		if err != nil {
+			slog.Error("Failed to send event status email.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+		}
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"The event is now " + string(e.Status) + " and attendees have been notified.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, eventURL, http.StatusFound)
+	return
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * The feedback form of an event, for its attendees once it's over.
+ *
+ * Path: /events/{event-id}/feedback
+ */
+func (a *app) feedbackGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !a.checkFeedback(w, r, e, u) {
+		return
+	}
+
+	feedback, err := db.GetFeedback(a.DB, e.ID, u.ID)
+	if err != nil {
+		feedback = &db.Feedback{}
+	}
+
+	renderPage(a, "events/feedback", w, r, map[string]interface{}{
+		"User":     u,
+		"Event":    e,
+		"Feedback": feedback,
+		"Stars":    []int{1, 2, 3, 4, 5},
+	})
+}
+
+/*
+ * Saves an attendee's feedback for an event.
+ *
+ * Path: /events/{event-id}/feedback
+ */
+func (a *app) feedbackPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !a.checkFeedback(w, r, e, u) {
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	rating, err := strconv.Atoi(r.Form.Get("rating"))
+	if err == nil {
+		_, err = db.SaveFeedback(a.DB, e.ID, u.ID, rating, r.Form.Get("comments"))
+	}
+	if err != nil {
+
+		slog.Error("Failed to save feedback.", "eventID", e.ID, "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save your feedback. Pick a rating from 1 to 5.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString()+"/feedback", http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"Thanks for your feedback!",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+}
+
+/*
+ * Shows the ratings of an event and the comments attendees left, without who
+ * left them.
+ *
+ * Path: /events/{event-id}/ratings
+ */
+func (a *app) ratingsGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to view the ratings.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	ratings, err := db.GetRatingsByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get ratings.", "eventID", e.ID, "err", err)
+	}
+
+	comments, err := db.GetFeedbackComments(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get feedback comments.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/ratings", w, r, map[string]interface{}{
+		"User":     u,
+		"Event":    e,
+		"Ratings":  ratings,
+		"Comments": comments,
+	})
+}
+
+/*
+ * Shows the ratings of the events of a group over time.
+ *
+ * Path: /groups/{group-id}/ratings
+ */
+func (a *app) groupsRatingsGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !g.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to view the ratings.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	events, err := db.GetRatingsByGroup(a.DB, g.ID)
+	if err != nil {
+		slog.Error("Failed to get group ratings.", "groupID", g.ID, "err", err)
+	}
+
+	var total db.Ratings
+	var sum float64
+	for _, e := range events {
+		total.Count += e.Count
+		total.Ones += e.Ones
+		total.Twos += e.Twos
+		total.Threes += e.Threes
+		total.Fours += e.Fours
+		total.Fives += e.Fives
+		sum += e.Average * float64(e.Count)
+	}
+
+	if total.Count > 0 {
+		total.Average = sum / float64(total.Count)
+	}
+
+	renderPage(a, "groups/ratings", w, r, map[string]interface{}{
+		"User":    u,
+		"Group":   g,
+		"Events":  events,
+		"Ratings": total,
+	})
+}
+
+/*
+ * checkFeedback makes sure the user can give feedback for the event, which
+ * takes it to be over and them to have attended. Otherwise they're sent back
+ * to the event and false is returned.
+ */
+func (a *app) checkFeedback(w http.ResponseWriter, r *http.Request, e *db.Event, u *db.User) bool {
+
+	session, _ := store.Get(r, "login")
+
+	message := ""
+	if !e.FeedbackOpen(time.Now()) {
+		message = "Feedback opens once the event is over."
+	} else if rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID); err != nil || !rsvp.CanGiveFeedback() {
+		message = "Only attendees can give feedback."
+	}
+
+	if message == "" {
+		return true
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashWarn,
+		message,
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+
+	return false
+}
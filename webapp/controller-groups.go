@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/eventhunt-org/webapp/framework"
 	"github.com/eventhunt-org/webapp/webapp/db"
@@ -60,14 +62,353 @@ func (a *app) groupsSingle(w http.ResponseWriter, r *http.Request) {
 	renderPage(a, "groups/single", w, r, map[string]interface{}{
 		"User":  u,
 		"Group": g,
+		"Meta":  groupMeta(g),
 	})
 }
 
-func (this *app) GeventsEditGet(w http.ResponseWriter, r *http.Request) {
+/*
+ * The settings of a group.
+ *
+ * Path: /groups/{group-id}/edit
+ */
+func (a *app) groupsEditGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !a.checkOwner(w, r, g, u) {
+		return
+	}
+
+	cities, err := db.GetCitiesByAll(a.DB)
+	if err != nil {
+
+		slog.Error("Failed to get all cities.", "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Cities list failed to load.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	renderPage(a, "groups/edit", w, r, map[string]interface{}{
+		"User":   u,
+		"Group":  g,
+		"Cities": cities,
+	})
 }
 
-func (a *app) GeventsEditPost(w http.ResponseWriter, r *http.Request) {
+/*
+ * Saves the settings of a group.
+ *
+ * Path: /groups/{group-id}/edit
+ */
+func (a *app) groupsEditPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	editURL := "/groups/" + g.IDString() + "/edit"
+
+	if !a.checkOwner(w, r, g, u) {
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	city := r.Form.Get("city")
+	cityID, err := strconv.ParseUint(city, 10, 64)
+	if err != nil {
+
+		slog.Error("City ID is not valid.", "id", city)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"City was invalid.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	// Checked here rather than in Group.Save so that older groups with a
+	// website that isn't a URL can still be archived.
+	groupURL := strings.TrimSpace(r.Form.Get("group-url"))
+	if groupURL != "" && (!isWebURL(groupURL) || len(groupURL) > 1000) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"The website must be a link starting with http:// or https://.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	g.Name = strings.TrimSpace(r.Form.Get("group-name"))
+	g.Summary = strings.TrimSpace(r.Form.Get("group-summary"))
+	g.Description = strings.TrimSpace(r.Form.Get("group-description"))
+	g.WebURL = groupURL
+	g.CityID = cityID
+	g.IsPrivate = r.Form.Get("is-private") == "1"
+
+	err = g.Save()
+	if err != nil {
+
+		slog.Error("Failed to save group.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save the group. Check the name and summary.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"The group was saved.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+}
+
+/*
+ * Archives a group or brings it back.
+ *
+ * Path: /groups/{group-id}/{action:archive|unarchive}
+ */
+func (a *app) groupsArchivePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !a.checkOwner(w, r, g, u) {
+		return
+	}
+
+	var err error
+	var message string
+
+	if chi.URLParam(r, "action") == "archive" {
+
+		err = g.Archive()
+		message = "The group was archived. It's hidden from group listings and can't schedule events until it's unarchived."
+	} else {
+
+		err = g.Unarchive()
+		message = "The group was unarchived."
+	}
+
+	if err != nil {
+
+		slog.Error("Failed to archive group.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to update the group.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString()+"/edit", http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		message,
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+}
+
+/*
+ * Asks to confirm deleting a group and shows what would be deleted with it.
+ *
+ * Path: /groups/{group-id}/delete
+ */
+func (a *app) groupsDeleteGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !a.checkOwner(w, r, g, u) {
+		return
+	}
+
+	deletion, err := db.GetGroupDeletion(a.DB, g.ID)
+	if err != nil {
+
+		slog.Error("Failed to get what deleting the group removes.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to load the group.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString()+"/edit", http.StatusFound)
+		return
+	}
+
+	renderPage(a, "groups/delete", w, r, map[string]interface{}{
+		"User":     u,
+		"Group":    g,
+		"Deletion": deletion,
+		"Blocked":  groupDeletionBlocked(deletion),
+	})
+}
+
+/*
+ * Deletes a group once its name was typed in to confirm.
+ *
+ * Path: /groups/{group-id}/delete
+ */
+func (a *app) groupsDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	deleteURL := "/groups/" + g.IDString() + "/delete"
+
+	if !a.checkOwner(w, r, g, u) {
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	if strings.TrimSpace(r.Form.Get("confirm-name")) != g.Name {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Type the name of the group to confirm deleting it.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, deleteURL, http.StatusFound)
+		return
+	}
+
+	// things could have changed since the confirmation page
+	deletion, err := db.GetGroupDeletion(a.DB, g.ID)
+	if err == nil {
+		if blocked := groupDeletionBlocked(deletion); blocked != "" {
+			err = errors.New(blocked)
+		}
+	}
+	if err == nil {
+		err = g.Delete()
+	}
+	if err != nil {
+
+		slog.Error("Failed to delete group.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to delete the group. " + err.Error(),
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, deleteURL, http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		g.Name + " was deleted.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups", http.StatusFound)
+}
+
+/*
+ * checkOwner makes sure the user owns the group. Otherwise they're sent back
+ * to the group and false is returned.
+ */
+func (a *app) checkOwner(w http.ResponseWriter, r *http.Request, g *db.Group, u *db.User) bool {
+
+	if g.IsOwner(u.ID) {
+		return true
+	}
+
+	session, _ := store.Get(r, "login")
+
+	session.AddFlash(framework.Flash{
+		framework.FlashFail,
+		"Only the owner of the group can change its settings.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+
+	return false
+}
+
+/*
+ * checkNotArchived makes sure the group can schedule events. Otherwise the user
+ * is sent back to the group and false is returned.
+ */
+func (a *app) checkNotArchived(w http.ResponseWriter, r *http.Request, g *db.Group) bool {
+
+	if !g.IsArchived() {
+		return true
+	}
+
+	session, _ := store.Get(r, "login")
+
+	session.AddFlash(framework.Flash{
+		framework.FlashFail,
+		"This group is archived. Unarchive it to schedule events.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+
+	return false
+}
+
+/*
+ * groupDeletionBlocked returns why the group can't be deleted, or an empty
+ * string if it can.
+ */
+func groupDeletionBlocked(d *db.GroupDeletion) string {
+
+	if d.Orders > 0 {
+		return "The group has paid orders that weren't refunded. Refund them or archive the group instead."
+	}
+
+	if d.Upcoming > 0 {
+		return "The group has upcoming events that people RSVP'd to. Cancel them first so that attendees are told."
+	}
 
+	return ""
 }
 
 /*
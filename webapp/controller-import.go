@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * The form to import events into a group from an iCalendar file.
+ *
+ * Path: /groups/{group-id}/import
+ */
+func (a *app) importGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !a.checkImport(w, r, g, u) {
+		return
+	}
+
+	renderPage(a, "groups/import", w, r, map[string]interface{}{
+		"User":  u,
+		"Group": g,
+	})
+}
+
+/*
+ * Previews the events of an uploaded iCalendar file. The preview carries the
+ * file so that confirming it imports the events without another upload.
+ *
+ * Path: /groups/{group-id}/import
+ */
+func (a *app) importPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	importURL := "/groups/" + g.IDString() + "/import"
+
+	if !a.checkImport(w, r, g, u) {
+		return
+	}
+
+	// the file is posted back with the preview, escaped
+	r.Body = http.MaxBytesReader(w, r.Body, 4*icalImportMaxSize)
+	err := r.ParseMultipartForm(icalImportMaxSize)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+
+		slog.Error("Failed to parse import form.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"The file is too large to import.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, importURL, http.StatusFound)
+		return
+	}
+	defer r.Body.Close()
+
+	confirm := r.Form.Get("confirm") == "1"
+
+	data := r.Form.Get("ics")
+	if !confirm {
+		data, err = readImportFile(r)
+	}
+
+	var events []*icalImportEvent
+	if err == nil {
+
+		timezone, tzErr := db.GetCityTimezone(a.DB, g.CityID)
+		if tzErr != nil {
+			slog.Error("Failed to get timezone of group city.", "groupID", g.ID, "err", tzErr)
+		}
+
+		events, err = parseICalImport(strings.NewReader(data), timezone)
+	}
+	if err == nil {
+		err = a.planICalImport(g, events)
+	}
+	if err != nil {
+
+		slog.Error("Failed to read import file.", "groupID", g.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to read the file. " + err.Error(),
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, importURL, http.StatusFound)
+		return
+	}
+
+	if !confirm {
+
+		toImport := 0
+		for _, ie := range events {
+			if ie.Skip == "" {
+				toImport++
+			}
+		}
+
+		renderPage(a, "groups/import", w, r, map[string]interface{}{
+			"User":     u,
+			"Group":    g,
+			"Preview":  true,
+			"Events":   events,
+			"ToImport": toImport,
+			"Max":      icalImportMax,
+			"ICS":      data,
+		})
+		return
+	}
+
+	count, err := a.importICal(u, g, events, r.Form.Get("publish") == "1")
+	if err != nil {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			"Some events failed to import.",
+		})
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"Imported " + strconv.Itoa(count) + " events.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+}
+
+/*
+ * checkImport makes sure the user can import events into the group and that
+ * it isn't archived. Otherwise they're sent back to the group and false is
+ * returned.
+ */
+func (a *app) checkImport(w http.ResponseWriter, r *http.Request, g *db.Group, u *db.User) bool {
+
+	if g.HasCreate(u.ID) {
+		return a.checkNotArchived(w, r, g)
+	}
+
+	session, _ := store.Get(r, "login")
+
+	session.AddFlash(framework.Flash{
+		framework.FlashFail,
+		"You don't have permission to import events into this group.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+
+	return false
+}
+
+/*
+ * readImportFile returns the content of the uploaded iCalendar file.
+ */
+func readImportFile(r *http.Request) (string, error) {
+
+	f, _, err := r.FormFile("ics-file")
+	if err != nil {
+		return "", errors.New("Pick a file to import.")
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, icalImportMaxSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(b) > icalImportMaxSize {
+		return "", errors.New("The file is larger than 2MB.")
+	}
+
+	return string(b), nil
+}
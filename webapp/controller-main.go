@@ -19,6 +19,32 @@ func (a *app) homepage(w http.ResponseWriter, r *http.Request) {
 
 	mapKey := viper.GetString("app_map_key")
 
+	// With a home city, the homepage shows what's around it
+	if ok && u.CityID != nil {
+		if point, city := a.cityPoint(*u.CityID); point != nil {
+
+			events, err := db.GetEventsNear(a.DB, *point, db.NearbyRadius, u.ID, 100)
+			if err != nil {
+				slog.Error("Failed to get events near home city.", "userID", u.ID, "err", err)
+			}
+
+			groups, err := db.GetGroupsNear(a.DB, *point, db.NearbyRadius, u.ID, 25)
+			if err != nil {
+				slog.Error("Failed to get groups near home city.", "userID", u.ID, "err", err)
+			}
+
+			renderPage(a, "homepage", w, r, map[string]interface{}{
+				"User":         u,
+				"MapKey":       mapKey,
+				"HomeCity":     city,
+				"Radius":       db.NearbyRadius,
+				"NearbyEvents": events,
+				"NearbyGroups": groups,
+			})
+			return
+		}
+	}
+
 	events, err := db.GetEvents(a.DB, 100)
 	if err != nil {
 		slog.Error(err.Error())
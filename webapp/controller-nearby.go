@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+// nearbyMaxRadius keeps "near me" searches to a reasonable area, in kilometers.
+const nearbyMaxRadius = 500
+
+/*
+ * Events and groups within a radius of a city or of coordinates, the closest
+ * first. Without either, the user's home city is used.
+ *
+ * Path: /near?city={city-id}&radius={km} or /near?lat={lat}&lng={lng}&radius={km}
+ */
+func (a *app) nearbyGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareUser might give us a User
+	u, _ := r.Context().Value("user").(*db.User)
+
+	params := r.URL.Query()
+
+	var userID uint64
+	if u != nil {
+		userID = u.ID
+	}
+
+	radius := float64(db.NearbyRadius)
+	if value, err := strconv.ParseFloat(params.Get("radius"), 64); err == nil && value > 0 {
+		radius = min(value, nearbyMaxRadius)
+	}
+
+	var point *db.Point
+	var city *db.City
+
+	lat, latErr := strconv.ParseFloat(params.Get("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(params.Get("lng"), 64)
+
+	if latErr == nil && lngErr == nil && lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180 {
+
+		point = &db.Point{Lat: lat, Lng: lng}
+	} else {
+
+		cityID, err := strconv.ParseUint(params.Get("city"), 10, 64)
+		if err != nil && u != nil && u.CityID != nil {
+			cityID, err = *u.CityID, nil
+		}
+
+		if err == nil {
+			point, city = a.cityPoint(cityID)
+		}
+	}
+
+	var events []*db.NearbyEvent
+	var groups []*db.NearbyGroup
+
+	if point != nil {
+
+		var err error
+
+		events, err = db.GetEventsNear(a.DB, *point, radius, userID, 50)
+		if err != nil {
+			slog.Error("Failed to get events near.", "lat", point.Lat, "lng", point.Lng, "err", err)
+		}
+
+		groups, err = db.GetGroupsNear(a.DB, *point, radius, userID, 50)
+		if err != nil {
+			slog.Error("Failed to get groups near.", "lat", point.Lat, "lng", point.Lng, "err", err)
+		}
+	}
+
+	cities, err := db.GetCitiesByAll(a.DB)
+	if err != nil {
+		slog.Error("Failed to get all cities.", "err", err)
+	}
+
+	renderPage(a, "other/near", w, r, map[string]interface{}{
+		"User":   u,
+		"Point":  point,
+		"City":   city,
+		"Radius": radius,
+		"Events": events,
+		"Groups": groups,
+		"Cities": cities,
+	})
+}
+
+/*
+ * cityPoint returns the city and its location, or nils if it doesn't exist.
+ */
+func (a *app) cityPoint(cityID uint64) (*db.Point, *db.City) {
+
+	point, err := db.GetCityPoint(a.DB, cityID)
+	if err != nil {
+		slog.Error("Failed to get location of city.", "cityID", cityID, "err", err)
+		return nil, nil
+	}
+
+	city, err := db.GetCityByID(a.DB, cityID)
+	if err != nil {
+		slog.Error("Failed to get city.", "cityID", cityID, "err", err)
+		return nil, nil
+	}
+
+	return point, city
+}
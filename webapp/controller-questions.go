@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/csv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * Lists the registration questions of an event, with a form to add one.
+ *
+ * Path: /events/{event-id}/questions
+ */
+func (a *app) questionsGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the questions of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	questions, err := db.GetQuestionsByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get questions.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/questions", w, r, map[string]interface{}{
+		"User":      u,
+		"Event":     e,
+		"Questions": questions,
+	})
+}
+
+/*
+ * Adds a registration question to an event.
+ *
+ * Path: /events/{event-id}/questions
+ */
+func (a *app) questionsPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	questionsURL := "/events/" + e.IDString() + "/questions"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the questions of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	_, err := db.NewQuestion(
+		a.DB,
+		e.ID,
+		r.Form.Get("label"),
+		db.QuestionKind(r.Form.Get("kind")),
+		strings.Split(r.Form.Get("choices"), "\n"),
+		r.Form.Get("required") == "1",
+	)
+	if err != nil {
+
+		slog.Error("Failed to create question.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to add the question. " + err.Error(),
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, questionsURL, http.StatusFound)
+}
+
+/*
+ * Removes a registration question, along with its answers.
+ *
+ * Path: /events/{event-id}/questions/{question-id}/delete
+ */
+func (a *app) questionsDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	questionsURL := "/events/" + e.IDString() + "/questions"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the questions of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	questionID, err := strconv.ParseUint(chi.URLParam(r, "question-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	q, err := db.GetQuestionByID(a.DB, questionID)
+	if err == nil && q.EventID != e.ID {
+		a.util404Get(w, r)
+		return
+	}
+	if err == nil {
+		err = q.Delete()
+	}
+	if err != nil {
+
+		slog.Error("Failed to delete question.", "eventID", e.ID, "questionID", questionID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to delete the question.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, questionsURL, http.StatusFound)
+}
+
+/*
+ * Shows the answers to the registration questions of an event.
+ *
+ * Path: /events/{event-id}/answers
+ */
+func (a *app) answersGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermAnswers) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to see the answers.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	questions, rows, err := a.answerRows(e)
+	if err != nil {
+		slog.Error("Failed to get answers.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/answers", w, r, map[string]interface{}{
+		"User":      u,
+		"Event":     e,
+		"Questions": questions,
+		"Rows":      rows,
+	})
+}
+
+/*
+ * Downloads the answers to the registration questions of an event as CSV.
+ *
+ * Path: /events/{event-id}/answers.csv
+ */
+func (a *app) answersCSVGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermAnswers) {
+		respondWithError(w, http.StatusForbidden, "You don't have permission to see the answers.")
+		return
+	}
+
+	questions, rows, err := a.answerRows(e)
+	if err != nil {
+		slog.Error("Failed to get answers.", "eventID", e.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to get the answers.")
+		return
+	}
+
+	header := []string{"Username", "RSVP"}
+	for _, q := range questions {
+		header = append(header, q.Label)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="event-`+e.IDString()+`-answers.csv"`)
+
+	c := csv.NewWriter(w)
+	c.Write(csvSafe(header))
+
+	for _, row := range rows {
+
+		status := string(row.RSVP.Intent)
+		if row.RSVP.IsWaitlisted() {
+			status = "waitlist"
+		}
+
+		record := []string{row.RSVP.TheUser.Username, status}
+		for _, answer := range row.Answers {
+			record = append(record, answer.String())
+		}
+
+		c.Write(csvSafe(record))
+	}
+
+	c.Flush()
+	if err := c.Error(); err != nil {
+		slog.Error("Failed to write answers CSV.", "eventID", e.ID, "err", err)
+	}
+}
+
+/*
+ * answerRows returns the questions of an event and everyone's answers to
+ * them.
+ */
+func (a *app) answerRows(e *db.Event) ([]*db.Question, []*db.AnswerRow, error) {
+
+	questions, err := db.GetQuestionsByEvent(a.DB, e.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.GetAnswerRowsByEvent(a.DB, e.ID, questions)
+
+	return questions, rows, err
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/eventhunt-org/webapp/framework"
 	"github.com/eventhunt-org/webapp/webapp/db"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/gorilla/sessions"
 )
 
 /*
- * Save an RSVP status.
+ * Save an RSVP status. When the event, or the chosen track of a hybrid event,
+ * is full, a "yes" puts the user on the waitlist instead.
  */
 func (a *app) rsvpsInput(w http.ResponseWriter, r *http.Request) {
 
@@ -25,14 +30,11 @@ func (a *app) rsvpsInput(w http.ResponseWriter, r *http.Request) {
 
 	rsvpIntent := db.RSVPStatus(chi.URLParam(r, "status"))
 
-	// check if we have an existing RSVP first
-	rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID)
-	if err != nil && err != pgx.ErrNoRows {
+	if e.IsCancelled() || e.IsDraft() {
 
-		slog.Error("Failed to check RSVP status.", "err", err)
 		session.AddFlash(framework.Flash{
-			framework.FlashFail,
-			"Failed to RSVP.",
+			framework.FlashWarn,
+			"This event isn't taking RSVPs.",
 		})
 
 		session.Save(r, w)
@@ -40,20 +42,267 @@ func (a *app) rsvpsInput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// we have an RSVP so let's just update it
-	if rsvp != nil {
-		rsvp.Intent = rsvpIntent
-		rsvp.DB = a.DB
-		rsvp.Save()
-	} else { // let's create a new one
-		_, err := db.NewRSVP(e.ID, u, rsvpIntent, db.RSVPAttendee)
+	// Attending takes answering the registration questions, saying who comes
+	// along and getting a ticket first
+	order, _ := db.GetActiveOrder(a.DB, e.ID, u.ID)
+	ticketTypes := e.TicketTypes()
+	needsTicket := len(ticketTypes) > 0 && order == nil
+
+	if questions := e.Questions(); (len(questions) > 0 || e.GuestLimit > 0 || needsTicket) && rsvpIntent.IsAttending() {
+
+		answers, err := db.GetAnswers(a.DB, e.ID, u.ID, questions)
 		if err != nil {
 
-			slog.Error("Failed to RSVP.", "err", err)
+			slog.Error("Failed to get answers.", "eventID", e.ID, "userID", u.ID, "err", err)
 			session.AddFlash(framework.Flash{
 				framework.FlashFail,
 				"Failed to RSVP.",
 			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+			return
+		}
+
+		// keep the guests of a previous RSVP
+		var guests int
+		var guestNames []string
+		if rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID); err == nil {
+			guests = rsvp.Guests
+			guestNames = rsvp.GuestNames
+		}
+
+		if !needsTicket {
+			ticketTypes = nil
+		}
+
+		renderPage(a, "events/rsvp", w, r, map[string]interface{}{
+			"User":        u,
+			"Event":       e,
+			"Intent":      rsvpIntent,
+			"Answers":     answers,
+			"Guests":      guests,
+			"GuestNames":  strings.Join(guestNames, "\n"),
+			"TicketTypes": ticketTypes,
+			"TicketID":    "",
+			"Order":       order,
+			"Now":         time.Now(),
+		})
+		return
+	}
+
+	a.saveRSVP(w, r, e, u, rsvpIntent, 0, nil, nil, nil)
+}
+
+/*
+ * Save an RSVP along with the guests and the answers to the registration
+ * questions of the event.
+ *
+ * Path: /events/{event-id}/rsvp/{status}
+ */
+func (a *app) rsvpsInputPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a User
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	rsvpIntent := db.RSVPStatus(chi.URLParam(r, "status"))
+
+	if e.IsCancelled() || e.IsDraft() {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			"This event isn't taking RSVPs.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	var answers []*db.Answer
+	var problems []string
+
+	for _, q := range e.Questions() {
+
+		answer, err := q.Answer(r.Form["question-"+q.IDString()])
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+
+		answers = append(answers, answer)
+	}
+
+	guests := 0
+	if g := r.Form.Get("guests"); g != "" {
+
+		var err error
+		guests, err = strconv.Atoi(g)
+		if err != nil || guests < 0 || guests > e.GuestLimit {
+			problems = append(problems, "You can bring up to "+strconv.Itoa(e.GuestLimit)+" guests.")
+		}
+	}
+
+	// A ticket covers the member and their guests. Those who already have
+	// one keep it.
+	var ticketType *db.TicketType
+	order, _ := db.GetActiveOrder(a.DB, e.ID, u.ID)
+	ticketTypes := e.TicketTypes()
+
+	if order != nil && 1+guests > order.Quantity {
+		problems = append(problems, "Your order covers "+strconv.Itoa(order.Quantity)+" people. Cancel your RSVP to order tickets for more guests.")
+	} else if order == nil && len(ticketTypes) > 0 && rsvpIntent.IsAttending() {
+
+		for _, t := range ticketTypes {
+			if t.IDString() == r.Form.Get("ticket") {
+				ticketType = t
+			}
+		}
+
+		if ticketType == nil {
+			problems = append(problems, "Pick a ticket.")
+		} else if !ticketType.IsOnSale(time.Now()) {
+			problems = append(problems, "\""+ticketType.Name+"\" tickets aren't on sale.")
+		} else if !ticketType.Available(1 + guests) {
+			problems = append(problems, "There aren't enough \""+ticketType.Name+"\" tickets left.")
+		}
+	} else {
+		ticketTypes = nil
+	}
+
+	// show the form again as it was filled
+	if len(problems) > 0 {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			strings.Join(problems, " "),
+		})
+
+		renderPage(a, "events/rsvp", w, r, map[string]interface{}{
+			"User":        u,
+			"Event":       e,
+			"Intent":      rsvpIntent,
+			"Answers":     answers,
+			"Guests":      guests,
+			"GuestNames":  r.Form.Get("guest-names"),
+			"TicketTypes": ticketTypes,
+			"TicketID":    r.Form.Get("ticket"),
+			"Order":       order,
+			"Now":         time.Now(),
+		})
+		return
+	}
+
+	var newOrder *db.Order
+	if ticketType != nil {
+
+		var err error
+		newOrder, err = a.orderTicket(e, u, ticketType, rsvpIntent, 1+guests, r.Form.Get("payment-token"))
+		if err != nil {
+
+			slog.Error("Failed to order ticket.", "eventID", e.ID, "userID", u.ID, "ticketID", ticketType.ID, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Failed to order the ticket. " + err.Error(),
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+			return
+		}
+	}
+
+	a.saveRSVP(w, r, e, u, rsvpIntent, guests, strings.Split(r.Form.Get("guest-names"), "\n"), answers, newOrder)
+}
+
+/*
+ * saveRSVP saves the RSVP then the answers, if any, and goes back to the
+ * event. The order just placed for the RSVP, if any, is released when the
+ * RSVP doesn't get a spot. Not attending anymore releases the tickets held.
+ */
+func (a *app) saveRSVP(w http.ResponseWriter, r *http.Request, e *db.Event, u *db.User, rsvpIntent db.RSVPStatus, guests int, guestNames []string, answers []*db.Answer, order *db.Order) {
+
+	session, _ := store.Get(r, "login")
+
+	// only new spots get a confirmation email
+	wasConfirmed := false
+	if previous, err := db.GetRSVP(a.DB, e.ID, u.ID); err == nil {
+		wasConfirmed = previous.IsConfirmed()
+	}
+
+	rsvp, promoted, err := db.SetRSVP(e, u, rsvpIntent, guests, guestNames)
+	if err == nil && order != nil && rsvp.IsWaitlisted() {
+
+		// the last spots went while the ticket was being paid
+		_, promoted, err = db.SetRSVP(e, u, db.RSVPNo, 0, nil)
+		if err == nil {
+			err = errors.New("The event filled up while your ticket was being ordered.")
+		}
+	}
+	if err != nil {
+
+		slog.Error("Failed to RSVP.", "eventID", e.ID, "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to RSVP. " + err.Error(),
+		})
+
+		if order != nil {
+			a.releaseTickets(session, order)
+		}
+		a.notifyPromoted(e, promoted)
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if rsvp.IsWaitlisted() {
+
+		full := "This event is full."
+		if e.IsHybrid() {
+			full = "The " + string(rsvp.Intent) + " spots are full."
+		}
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			full + " You're #" + strconv.Itoa(*rsvp.WaitlistPosition) + " on the waitlist and will get an email if a spot opens up.",
+		})
+	}
+
+	a.notifyPromoted(e, promoted)
+
+	if rsvp.IsConfirmed() && !wasConfirmed {
+
+		err = sendEmailRSVPConfirmed(u, e, a.rsvpTicket(e, rsvp))
+		if err != nil {
+			slog.Error("Failed to send RSVP confirmation email.", "eventID", e.ID, "userID", u.ID, "err", err)
+		}
+	}
+
+	if !rsvp.Intent.IsAttending() {
+
+		if held, err := db.GetActiveOrder(a.DB, e.ID, u.ID); err == nil {
+			a.releaseTickets(session, held)
+		}
+	}
+
+	if len(answers) > 0 {
+
+		err = db.SaveAnswers(a.DB, e.ID, u.ID, answers)
+		if err != nil {
+
+			slog.Error("Failed to save answers.", "eventID", e.ID, "userID", u.ID, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Your RSVP was saved but your answers weren't. Please try again.",
+			})
 		}
 	}
 
@@ -61,3 +310,172 @@ func (a *app) rsvpsInput(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
 	return
 }
+
+/*
+ * releaseTickets releases the tickets of an order and lets the user know
+ * whether they were refunded.
+ */
+func (a *app) releaseTickets(session *sessions.Session, o *db.Order) {
+
+	paid := o.IsPaid()
+
+	err := a.releaseOrder(o)
+	if err != nil {
+
+		slog.Error("Failed to release order.", "orderID", o.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Your ticket couldn't be refunded. Please contact the hosts.",
+		})
+		return
+	}
+
+	if paid {
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"Your ticket was refunded.",
+		})
+	}
+}
+
+/*
+ * Shows the waitlist of an event to its hosts.
+ *
+ * Path: /events/{event-id}/waitlist
+ */
+func (a *app) rsvpsWaitlistGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	waitlist, err := db.GetWaitlistByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get waitlist.", "eventID", e.ID, "err", err)
+	}
+
+	tracks, err := db.GetTrackCountsByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to count attendees.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/waitlist", w, r, map[string]interface{}{
+		"User":      u,
+		"Event":     e,
+		"Waitlist":  waitlist,
+		"Confirmed": tracks.Of(db.RSVPYes),
+		"Tracks":    tracks,
+	})
+}
+
+/*
+ * Moves someone up or down the waitlist.
+ *
+ * Path: /events/{event-id}/waitlist
+ */
+func (a *app) rsvpsWaitlistPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	waitlistURL := "/events/" + e.IDString() + "/waitlist"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	userID, err := strconv.ParseUint(r.Form.Get("user-id"), 10, 64)
+	if err == nil {
+		err = db.MoveOnWaitlist(e, userID, r.Form.Get("move") == "up")
+	}
+	if err != nil {
+
+		slog.Error("Failed to reorder waitlist.", "eventID", e.ID, "user-id", r.Form.Get("user-id"), "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to reorder the waitlist.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, waitlistURL, http.StatusFound)
+	return
+}
+
+/*
+ * promoteWaitlist fills spots that opened up on an event, for example after
+ * its attendee limit was raised, and notifies the people promoted.
+ */
+func (a *app) promoteWaitlist(e *db.Event) {
+
+	promoted, err := db.PromoteWaitlist(e)
+	if err != nil {
+		slog.Error("Failed to promote waitlist.", "eventID", e.ID, "err", err)
+		return
+	}
+
+	a.notifyPromoted(e, promoted)
+}
+
+/*
+ * rsvpTicket returns the QR ticket of an RSVP as a PNG image, or nil when it
+ * doesn't get one.
+ */
+func (a *app) rsvpTicket(e *db.Event, rsvp *db.RSVP) []byte {
+
+	if !hasTicket(rsvp) {
+		return nil
+	}
+
+	ticket, err := a.ticketQR(e.ID, rsvp.UserID)
+	if err != nil {
+		slog.Error("Failed to make QR ticket.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+		return nil
+	}
+
+	return ticket
+}
+
+/*
+ * notifyPromoted emails the people who just got a spot off the waitlist.
+ */
+func (a *app) notifyPromoted(e *db.Event, promoted []*db.RSVP) {
+
+	for _, rsvp := range promoted {
+
+		err := sendEmailWaitlistPromoted(rsvp.TheUser, e, a.rsvpTicket(e, rsvp))
+		if err != nil {
+			slog.Error("Failed to send waitlist promotion email.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+		}
+	}
+}
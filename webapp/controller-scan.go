@@ -0,0 +1,209 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * scanResult is what the scanner shows after a ticket is scanned.
+ */
+type scanResult struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Name    string `json:"name,omitempty"`
+	Guests  int    `json:"guests"`
+}
+
+/*
+ * The QR ticket of the user for an event, as a PNG image.
+ *
+ * Path: /events/{event-id}/ticket.png
+ */
+func (a *app) ticketQRGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	rsvp, err := db.GetRSVP(a.DB, e.ID, u.ID)
+	if err != nil || !hasTicket(rsvp) {
+		a.util404Get(w, r)
+		return
+	}
+
+	png, err := a.ticketQR(e.ID, u.ID)
+	if err != nil {
+		slog.Error("Failed to make QR ticket.", "eventID", e.ID, "userID", u.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to make the ticket.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(png)
+}
+
+/*
+ * The ticket scanner for hosts and check-in staff.
+ *
+ * Path: /events/{event-id}/scan
+ */
+func (a *app) scanGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermCheckIn) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to check people in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	renderPage(a, "events/scan", w, r, map[string]interface{}{
+		"User":  u,
+		"Event": e,
+	})
+}
+
+/*
+ * Checks in the attendee of a scanned ticket. The scanner posts tickets in
+ * the background and gets JSON back, the form for typing a ticket in gets a
+ * flash.
+ *
+ * Path: /events/{event-id}/scan
+ */
+func (a *app) scanPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	wantsJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	if !e.Can(u.ID, db.EventPermCheckIn) {
+
+		if wantsJSON {
+			respondWithJSON(w, http.StatusForbidden, scanResult{Message: "You don't have permission to check people in."})
+			return
+		}
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to check people in.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	result := a.scanTicket(e, r.Form.Get("ticket"))
+
+	if wantsJSON {
+		respondWithJSON(w, http.StatusOK, result)
+		return
+	}
+
+	flash := framework.FlashSuccess
+	if !result.OK {
+		flash = framework.FlashFail
+	}
+
+	message := result.Message
+	if result.Name != "" {
+		message = result.Name + ": " + message
+	}
+
+	session.AddFlash(framework.Flash{
+		flash,
+		message,
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+e.IDString()+"/scan", http.StatusFound)
+}
+
+/*
+ * scanTicket checks the ticket in if it's one of the event and its RSVP is
+ * still confirmed. Scanning a ticket twice is fine, hosts are told it was
+ * already checked in.
+ */
+func (a *app) scanTicket(e *db.Event, code string) scanResult {
+
+	eventID, userID, err := a.parseTicketCode(code)
+	if err != nil {
+		return scanResult{Message: err.Error()}
+	}
+
+	if eventID != e.ID {
+		return scanResult{Message: "The ticket is for another event."}
+	}
+
+	rsvp, err := db.GetRSVP(a.DB, e.ID, userID)
+	if err != nil {
+		return scanResult{Message: "The RSVP of this ticket is gone."}
+	}
+
+	attendee, err := db.GetUserByID(a.DB, userID)
+	if err != nil {
+		return scanResult{Message: "The attendee of this ticket is gone."}
+	}
+
+	result := scanResult{
+		Name:   attendee.Username,
+		Guests: rsvp.Guests,
+	}
+	if name := strings.TrimSpace(attendee.FirstName + " " + attendee.LastName); name != "" {
+		result.Name = name + " (" + attendee.Username + ")"
+	}
+
+	if !hasTicket(rsvp) {
+		result.Message = "The RSVP of this ticket isn't confirmed anymore."
+		return result
+	}
+
+	result.OK = true
+
+	if rsvp.Actual != nil && *rsvp.Actual == db.RSVPInPerson {
+		result.Message = "Already checked in."
+		return result
+	}
+
+	inPerson := db.RSVPInPerson
+	err = db.CheckIn(e, userID, &inPerson)
+	if err != nil {
+
+		slog.Error("Failed to check in ticket.", "eventID", e.ID, "userID", userID, "err", err)
+		return scanResult{Name: result.Name, Message: "Failed to check in."}
+	}
+
+	result.Message = "Checked in."
+	if rsvp.Guests > 0 {
+		result.Message = "Checked in, with " + strconv.Itoa(rsvp.Guests) + " guests."
+	}
+
+	return result
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * Removes a single occurrence from its recurring series by adding it as an
+ * EXDATE. If people RSVP'd to it, it's cancelled instead and they're notified.
+ *
+ * Path: /events/{event-id}/skip
+ */
+func (a *app) seriesSkipPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	// Only the owner of the group can add/edit an event
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to edit this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if e.SeriesID == nil || e.RecurrenceTime == nil {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashWarn,
+			"This event isn't part of a recurring series.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	s, err := db.GetSeriesByID(a.DB, *e.SeriesID)
+	if err != nil {
+
+		slog.Error("Failed to load series.", "seriesID", *e.SeriesID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to skip this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	err = s.AddExDate(*e.RecurrenceTime)
+	if err == nil {
+		err = a.syncSeries(s, u.ID)
+	}
+	if err != nil {
+
+		slog.Error("Failed to skip occurrence.", "seriesID", s.ID, "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to skip this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"The event on " + e.LocalStart().Format("January 2, 2006") + " was removed from the series.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/groups/"+strconv.FormatUint(e.GroupID, 10), http.StatusFound)
+	return
+}
+
+/*
+ * saveSeriesEdit applies an edit made to one occurrence to the rest of its
+ * series. Scope is either "all", for every upcoming occurrence, or
+ * "following", for this occurrence and the ones after it. The event must
+ * already have the edited values set. An empty rule keeps the current RRULE.
+ * The series that ends up holding the event is returned.
+ */
+func (a *app) saveSeriesEdit(e *db.Event, scope string, startTime, endTime time.Time, rule string) (*db.Series, error) {
+
+	s, err := db.GetSeriesByID(a.DB, *e.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Editing the first occurrence and the ones following it is the same as
+	// editing them all.
+	if scope == "following" && e.RecurrenceTime.After(s.StartTime) {
+
+		s, err = s.Split(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.Name = e.Name
+	s.Summary = e.Summary
+	s.Description = e.Description
+	s.WebURL = e.WebURL
+	s.AttendeeLimit = e.AttendeeLimit
+	s.OnlineLimit = e.OnlineLimit
+	s.GuestLimit = e.GuestLimit
+	s.Timezone = e.Timezone
+
+	err = s.Reschedule(startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule != "" {
+
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, err
+		}
+
+		opt, err := db.ParseRRule(rule, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		s.RRule = opt.RRuleString()
+	}
+
+	err = s.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	// The occurrence follows the series again, even if it was edited on its
+	// own before.
+	e.SeriesID = &s.ID
+	e.IsOverride = false
+	err = e.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, a.syncSeries(s, 0)
+}
+
+/*
+ * seriesApplyPlace copies the venue or location URL of an occurrence to its
+ * series, and in turn to the upcoming occurrences. When the series is being
+ * moved, RSVPs of the other occurrences are notified.
+ */
+func (a *app) seriesApplyPlace(e *db.Event, editor *db.User, moved bool) error {
+
+	s, err := db.GetSeriesByID(a.DB, *e.SeriesID)
+	if err != nil {
+		return err
+	}
+
+	s.VenueID = e.VenueID
+	s.LocationURL = e.LocationURL
+	s.OnlineLimit = e.OnlineLimit
+
+	// the venue can bring a new timezone, see venueNewPost
+	if s.Timezone != e.Timezone {
+
+		s.Timezone = e.Timezone
+		if !moved {
+			err = s.Reschedule(e.StartTime, e.EndTime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = s.Save()
+	if err != nil {
+		return err
+	}
+
+	err = a.syncSeries(s, editor.ID)
+	if err != nil {
+		return err
+	}
+
+	if !moved {
+		return nil
+	}
+
+	events, err := s.Events()
+	if err != nil {
+		return err
+	}
+
+	for _, occurrence := range events {
+
+		if occurrence.ID == e.ID || occurrence.IsOverride || occurrence.StartTime.Before(time.Now()) {
+			continue
+		}
+
+		a.notifyEventChanged(occurrence, editor, "venue")
+	}
+
+	return nil
+}
+
+/*
+ * syncSeries syncs the events of a series with its rule. The tickets of the
+ * occurrences that had to be cancelled are refunded and their attendees are
+ * notified, except for the user making the change. editorID is 0 when nobody
+ * is.
+ */
+func (a *app) syncSeries(s *db.Series, editorID uint64) error {
+
+	cancelled, err := s.Sync()
+
+	for _, e := range cancelled {
+		a.releaseEventOrders(e)
+		a.notifyCancelled(e, editorID)
+	}
+
+	return err
+}
+
+/*
+ * notifyCancelled emails everyone that RSVP'd to a cancelled event, except
+ * the user that cancelled it.
+ */
+func (a *app) notifyCancelled(e *db.Event, editorID uint64) {
+
+	rsvps, err := e.RSVPs()
+	if err != nil {
+		slog.Error("Failed to get RSVPs for cancelled event.", "eventID", e.ID, "err", err)
+		return
+	}
+
+	for _, rsvp := range rsvps {
+
+		if rsvp.UserID == editorID {
+			continue
+		}
+
+		err := sendEmailEventCancelled(rsvp.TheUser, e, e.StatusReason)
+		if err != nil {
+			slog.Error("Failed to send event cancelled email.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+		}
+	}
+}
+
+/*
+ * seriesRuleFromForm builds an RRULE from the repeat options of the schedule
+ * form. The start time must be in the timezone of the event. An empty string
+ * means the event doesn't repeat.
+ */
+func seriesRuleFromForm(form url.Values, start time.Time) (string, error) {
+
+	day := strings.ToUpper(start.Weekday().String()[:2])
+
+	var rule string
+
+	switch form.Get("repeat") {
+	case "":
+		return "", nil
+	case "custom":
+		return form.Get("rrule"), nil
+	case "daily":
+		rule = "FREQ=DAILY"
+	case "weekly":
+		rule = "FREQ=WEEKLY;BYDAY=" + day
+	case "biweekly":
+		rule = "FREQ=WEEKLY;INTERVAL=2;BYDAY=" + day
+	case "monthly-weekday":
+
+		// for example, the 2nd Tuesday of the month. The 5th weekday doesn't
+		// exist in every month so that becomes the last one.
+		nth := (start.Day()-1)/7 + 1
+		if nth == 5 {
+			nth = -1
+		}
+		rule = "FREQ=MONTHLY;BYDAY=" + strconv.Itoa(nth) + day
+	case "monthly-day":
+		rule = "FREQ=MONTHLY;BYMONTHDAY=" + strconv.Itoa(start.Day())
+	default:
+		return "", errors.New("Unknown repeat option.")
+	}
+
+	if until := form.Get("repeat-until"); until != "" {
+
+		untilDate, err := time.ParseInLocation(time.DateOnly, until, start.Location())
+		if err != nil {
+			return "", err
+		}
+
+		rule = rule + ";UNTIL=" + untilDate.Format("20060102") + "T235959"
+	} else if count := form.Get("repeat-count"); count != "" {
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return "", errors.New("Invalid number of occurrences.")
+		}
+
+		rule = rule + ";COUNT=" + strconv.Itoa(n)
+	}
+
+	return rule, nil
+}
+
+/*
+ * parseSkipDates parses a list of dates (YYYY-MM-DD) separated by commas or
+ * new lines into EXDATEs. Each date gets the time of day of start.
+ */
+func parseSkipDates(value string, start time.Time) ([]time.Time, error) {
+
+	var exDates []time.Time
+
+	fields := strings.FieldsFunc(value, func(c rune) bool {
+		return c == ',' || c == '\n' || c == '\r' || c == ' '
+	})
+
+	for _, field := range fields {
+
+		date, err := time.ParseInLocation(time.DateOnly, field, start.Location())
+		if err != nil {
+			return nil, err
+		}
+
+		exDate := time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), 0, 0, start.Location())
+		exDates = append(exDates, exDate.UTC())
+	}
+
+	return exDates, nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * Lists the staff of an event, with a form to add someone as host or crew.
+ *
+ * Path: /events/{event-id}/staff
+ */
+func (a *app) staffGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the staff of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	staff, err := db.GetStaffByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get event staff.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/staff", w, r, map[string]interface{}{
+		"User":  u,
+		"Event": e,
+		"Staff": staff,
+	})
+}
+
+/*
+ * Gives a user, found by username or email address, the host or crew role on
+ * the event.
+ *
+ * Path: /events/{event-id}/staff
+ */
+func (a *app) staffPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	staffURL := "/events/" + e.IDString() + "/staff"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the staff of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	member := strings.TrimSpace(r.Form.Get("member"))
+	role := db.RSVPRole(r.Form.Get("role"))
+
+	staffer, err := db.GetUserByUsernameOrEmail(a.DB, member)
+	if err != nil {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"No user found with the username or email \"" + member + "\".",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, staffURL, http.StatusFound)
+		return
+	}
+
+	promoted, err := db.SetStaff(e, staffer.ID, role)
+	if err != nil {
+
+		slog.Error("Failed to add event staff.", "eventID", e.ID, "userID", staffer.ID, "role", role, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to add " + staffer.Username + " to the staff.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, staffURL, http.StatusFound)
+		return
+	}
+
+	a.notifyPromoted(e, promoted)
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		staffer.Username + " is now " + string(role) + " of this event.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, staffURL, http.StatusFound)
+}
+
+/*
+ * Takes the staff role away from a user.
+ *
+ * Path: /events/{event-id}/staff/{user-id}/remove
+ */
+func (a *app) staffRemovePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	staffURL := "/events/" + e.IDString() + "/staff"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the staff of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	userID, err := strconv.ParseUint(chi.URLParam(r, "user-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	err = db.RemoveStaff(e, userID)
+	if err != nil {
+
+		slog.Error("Failed to remove event staff.", "eventID", e.ID, "userID", userID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to remove from the staff.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, staffURL, http.StatusFound)
+}
+
+/*
+ * The form for messaging the attendees of an event.
+ *
+ * Path: /events/{event-id}/message
+ */
+func (a *app) staffMessageGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.Can(u.ID, db.EventPermMessage) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to message the attendees.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	renderPage(a, "events/message", w, r, map[string]interface{}{
+		"User":  u,
+		"Event": e,
+	})
+}
+
+/*
+ * Emails a message to the attendees of an event. The audience can include
+ * people who said maybe and the waitlist.
+ *
+ * Path: /events/{event-id}/message
+ */
+func (a *app) staffMessagePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	messageURL := "/events/" + e.IDString() + "/message"
+
+	if !e.Can(u.ID, db.EventPermMessage) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to message the attendees.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	subject := strings.TrimSpace(r.Form.Get("subject"))
+	body := strings.TrimSpace(r.Form.Get("body"))
+
+	// header injection
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+
+	if subject == "" || body == "" {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"A subject and a message are required.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, messageURL, http.StatusFound)
+		return
+	}
+
+	includeMaybe := r.Form.Get("maybe") == "1"
+	includeWaitlist := r.Form.Get("waitlist") == "1"
+
+	rsvps, err := e.RSVPs()
+	if err != nil {
+
+		slog.Error("Failed to get RSVPs for message.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to send the message.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, messageURL, http.StatusFound)
+		return
+	}
+
+	sent := 0
+	for _, rsvp := range rsvps {
+
+		if rsvp.UserID == u.ID || rsvp.IsStaff() {
+			continue
+		}
+
+		switch {
+		case rsvp.IsConfirmed():
+		case rsvp.IsWaitlisted() && includeWaitlist:
+		case rsvp.Intent == db.RSVPMaybe && includeMaybe:
+		default:
+			continue
+		}
+
+		err := sendEmailEventMessage(rsvp.TheUser, e, u, subject, body)
+		if err != nil {
+			slog.Error("Failed to send event message.", "eventID", e.ID, "userID", rsvp.UserID, "err", err)
+			continue
+		}
+
+		sent++
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"Your message was sent to " + strconv.Itoa(sent) + " attendees.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * Lists the ticket types of an event, with a form to add one.
+ *
+ * Path: /events/{event-id}/tickets
+ */
+func (a *app) ticketsGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the tickets of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	ticketTypes, err := db.GetTicketTypesByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get ticket types.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/tickets", w, r, map[string]interface{}{
+		"User":        u,
+		"Event":       e,
+		"TicketTypes": ticketTypes,
+		"PaidTickets": a.Payments != nil,
+	})
+}
+
+/*
+ * Adds a ticket type to an event. Sales times are in the timezone of the
+ * event.
+ *
+ * Path: /events/{event-id}/tickets
+ */
+func (a *app) ticketsPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	ticketsURL := "/events/" + e.IDString() + "/tickets"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the tickets of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	price, err := db.ParsePrice(r.Form.Get("price"))
+	if err == nil && price > 0 && a.Payments == nil {
+		err = errors.New("Payments aren't set up, only free tickets can be added.")
+	}
+
+	quantity := 0
+	if q := r.Form.Get("quantity"); q != "" && err == nil {
+
+		quantity, err = strconv.Atoi(q)
+		if err != nil || quantity < 0 {
+			err = errors.New("The quantity isn't valid.")
+		}
+	}
+
+	var salesTimes [2]*time.Time
+	for i, field := range []string{"sales-start", "sales-end"} {
+
+		if v := r.Form.Get(field); v != "" && err == nil {
+
+			t, parseErr := time.ParseInLocation("2006-01-02T15:04", v, e.Location())
+			if parseErr != nil {
+				err = errors.New("The sales times aren't valid.")
+			}
+
+			salesTimes[i] = &t
+		}
+	}
+
+	if err == nil {
+		_, err = db.NewTicketType(
+			a.DB,
+			e.ID,
+			r.Form.Get("name"),
+			price,
+			r.Form.Get("currency"),
+			quantity,
+			salesTimes[0],
+			salesTimes[1],
+		)
+	}
+	if err != nil {
+
+		slog.Error("Failed to create ticket type.", "eventID", e.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to add the ticket type. " + err.Error(),
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, ticketsURL, http.StatusFound)
+}
+
+/*
+ * Removes a ticket type that wasn't ordered yet.
+ *
+ * Path: /events/{event-id}/tickets/{ticket-id}/delete
+ */
+func (a *app) ticketsDeletePost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	ticketsURL := "/events/" + e.IDString() + "/tickets"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the tickets of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	ticketID, err := strconv.ParseUint(chi.URLParam(r, "ticket-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	t, err := db.GetTicketTypeByID(a.DB, ticketID)
+	if err == nil && t.EventID != e.ID {
+		a.util404Get(w, r)
+		return
+	}
+	if err == nil {
+		err = t.Delete()
+	}
+	if err != nil {
+
+		slog.Error("Failed to delete ticket type.", "eventID", e.ID, "ticketID", ticketID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to delete the ticket type. " + err.Error(),
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, ticketsURL, http.StatusFound)
+}
+
+/*
+ * Lists the orders of an event.
+ *
+ * Path: /events/{event-id}/orders
+ */
+func (a *app) ordersGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the tickets of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	orders, err := db.GetOrdersByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get orders.", "eventID", e.ID, "err", err)
+	}
+
+	renderPage(a, "events/orders", w, r, map[string]interface{}{
+		"User":   u,
+		"Event":  e,
+		"Orders": orders,
+	})
+}
+
+/*
+ * Refunds an order, or cancels it when it was free, and cancels the RSVP it
+ * was for.
+ *
+ * Path: /events/{event-id}/orders/{order-id}/refund
+ */
+func (a *app) ordersRefundPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareEvent ensures we have an Event
+	e := r.Context().Value("event").(*db.Event)
+
+	ordersURL := "/events/" + e.IDString() + "/orders"
+
+	if !e.TheGroup.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to manage the tickets of this event.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	orderID, err := strconv.ParseUint(chi.URLParam(r, "order-id"), 10, 64)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	o, err := db.GetOrderByID(a.DB, orderID)
+	if err == nil && o.EventID != e.ID {
+		a.util404Get(w, r)
+		return
+	}
+	if err == nil {
+		err = a.releaseOrder(o)
+	}
+	if err != nil {
+
+		slog.Error("Failed to refund order.", "eventID", e.ID, "orderID", orderID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to refund the order. " + err.Error(),
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, ordersURL, http.StatusFound)
+		return
+	}
+
+	// without a ticket, the spot goes back to the event
+	buyer, err := db.GetUserByID(a.DB, o.UserID)
+	if err == nil {
+
+		var promoted []*db.RSVP
+		_, promoted, err = db.SetRSVP(e, buyer, db.RSVPNo, 0, nil)
+		a.notifyPromoted(e, promoted)
+	}
+	if err != nil {
+
+		slog.Error("Failed to cancel RSVP of refunded order.", "eventID", e.ID, "orderID", orderID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"The order was refunded but the RSVP couldn't be cancelled.",
+		})
+	} else {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"The order was refunded and the RSVP cancelled.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, ordersURL, http.StatusFound)
+}
+
+/*
+ * orderTicket holds tickets for a user and their guests and pays for them
+ * with the payment details submitted. Tickets aren't sold past the capacity
+ * of the event, so that nobody pays for a spot on the waitlist.
+ */
+func (a *app) orderTicket(e *db.Event, u *db.User, t *db.TicketType, intent db.RSVPStatus, spots int, token string) (*db.Order, error) {
+
+	track := e.Track(e.Intent(intent))
+	if capacity := e.TrackCapacity(track); capacity > 0 && e.TrackCounts().Of(track)+spots > capacity {
+		return nil, errors.New("The event is full.")
+	}
+
+	if !t.IsFree() && a.Payments == nil {
+		return nil, errors.New("Paid tickets can't be bought at the moment.")
+	}
+
+	o, err := db.NewOrder(t, u.ID, spots, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if t.IsFree() {
+		return o, o.Confirm("", "")
+	}
+
+	ref, err := a.Payments.Charge(o, token)
+	if err != nil {
+
+		if cancelErr := o.Cancel(); cancelErr != nil {
+			slog.Error("Failed to cancel unpaid order.", "orderID", o.ID, "err", cancelErr)
+		}
+
+		return nil, err
+	}
+
+	err = o.Confirm(a.Payments.Name(), ref)
+	if err != nil {
+		return nil, errors.New("The payment went through but the order couldn't be saved. Please contact the hosts.")
+	}
+
+	return o, nil
+}
+
+/*
+ * releaseEventOrders releases every order of a cancelled event that still
+ * holds tickets, refunding the paid ones. The number of orders that couldn't
+ * be released is returned, they're logged.
+ */
+func (a *app) releaseEventOrders(e *db.Event) int {
+
+	orders, err := db.GetOrdersByEvent(a.DB, e.ID)
+	if err != nil {
+		slog.Error("Failed to get orders of cancelled event.", "eventID", e.ID, "err", err)
+		return 1
+	}
+
+	failed := 0
+
+	for _, o := range orders {
+
+		if !o.IsActive() {
+			continue
+		}
+
+		err = a.releaseOrder(o)
+		if err != nil {
+			slog.Error("Failed to release order of cancelled event.", "eventID", e.ID, "orderID", o.ID, "err", err)
+			failed++
+		}
+	}
+
+	return failed
+}
+
+/*
+ * releaseOrder gives the tickets of an order back, refunding it through the
+ * payment provider when it was paid.
+ */
+func (a *app) releaseOrder(o *db.Order) error {
+
+	if !o.IsActive() {
+		return errors.New("The order was already refunded or cancelled.")
+	}
+
+	if !o.IsPaid() {
+		return o.Cancel()
+	}
+
+	if a.Payments == nil || o.Provider != a.Payments.Name() {
+		return errors.New("The order was paid through " + o.Provider + ", which isn't set up anymore.")
+	}
+
+	err := a.Payments.Refund(o)
+	if err != nil {
+		return err
+	}
+
+	return o.MarkRefunded()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * Shows the form for choosing the user's home city.
+ *
+ * Path: /users/me/home-city
+ */
+func (a *app) usersHomeCityGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	cities, err := db.GetCitiesByAll(a.DB)
+	if err != nil {
+		slog.Error("Failed to get all cities.", "err", err)
+	}
+
+	var cityID uint64
+	if u.CityID != nil {
+		cityID = *u.CityID
+	}
+
+	renderPage(a, "users/home-city", w, r, map[string]interface{}{
+		"User":   u,
+		"Cities": cities,
+		"CityID": cityID,
+	})
+}
+
+/*
+ * Saves the user's home city. An empty value removes it.
+ *
+ * Path: /users/me/home-city
+ */
+func (a *app) usersHomeCityPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	var cityID *uint64
+
+	if city := r.Form.Get("city"); city != "" {
+
+		id, err := strconv.ParseUint(city, 10, 64)
+		if err == nil {
+			_, err = db.GetCityPoint(a.DB, id)
+		}
+		if err != nil {
+
+			slog.Error("City ID is not valid.", "id", city, "err", err)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"City was invalid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/users/me/home-city", http.StatusFound)
+			return
+		}
+
+		cityID = &id
+	}
+
+	err := u.SetHomeCity(cityID)
+	if err != nil {
+
+		slog.Error("Failed to save home city.", "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save your home city.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/users/me/home-city", http.StatusFound)
+		return
+	}
+
+	session.AddFlash(framework.Flash{
+		framework.FlashSuccess,
+		"Your home city has been saved.",
+	})
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+/*
+ * Shows the user's privacy settings.
+ *
+ * Path: /users/me/privacy
+ */
+func (a *app) usersPrivacyGet(w http.ResponseWriter, r *http.Request) {
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	renderPage(a, "users/privacy", w, r, map[string]interface{}{
+		"User": u,
+	})
+}
+
+/*
+ * Saves the user's privacy settings.
+ *
+ * Path: /users/me/privacy
+ */
+func (a *app) usersPrivacyPost(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+
+	r.ParseForm()
+	defer r.Body.Close()
+
+	err := u.SetShareEmail(r.Form.Get("share-email") == "1")
+	if err != nil {
+
+		slog.Error("Failed to save privacy settings.", "userID", u.ID, "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save your privacy settings.",
+		})
+	} else {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashSuccess,
+			"Your privacy settings have been saved.",
+		})
+	}
+
+	session.Save(r, w)
+	http.Redirect(w, r, "/users/me/privacy", http.StatusFound)
+}
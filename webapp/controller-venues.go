@@ -22,8 +22,8 @@ func (a *app) venueNew(w http.ResponseWriter, r *http.Request) {
 	// middlewareEvent ensures we have an Event
 	e := r.Context().Value("event").(*db.Event)
 
-	// Only the owner of the group can add/edit an event
-	if !e.TheGroup.HasCreate(u.ID) {
+	// Group hosts and event hosts can change the venue
+	if !e.Can(u.ID, db.EventPermVenue) {
 
 		session.AddFlash(framework.Flash{
 			framework.FlashFail,
@@ -68,8 +68,8 @@ func (a *app) venueNewPost(w http.ResponseWriter, r *http.Request) {
 	// middlewareEvent ensures we have an Event
 	e := r.Context().Value("event").(*db.Event)
 
-	// Only the owner of the group can add/edit an event
-	if !e.TheGroup.HasCreate(u.ID) {
+	// Group hosts and event hosts can change the venue
+	if !e.Can(u.ID, db.EventPermVenue) {
 
 		session.AddFlash(framework.Flash{
 			framework.FlashFail,
@@ -102,7 +102,25 @@ func (a *app) venueNewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	v, err := db.NewVenue(a.DB, name, address, cityID)
+	var capacity uint64
+	if c := r.Form.Get("capacity"); c != "" {
+
+		capacity, err = strconv.ParseUint(c, 10, 32)
+		if err != nil {
+
+			slog.Error("Venue capacity is not valid.", "capacity", c)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Capacity was invalid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+			return
+		}
+	}
+
+	v, err := db.NewVenue(a.DB, name, address, cityID, uint(capacity))
 	if err != nil {
 
 		slog.Error("Failed to create venue.", "err", err)
@@ -116,9 +134,61 @@ func (a *app) venueNewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A hybrid event keeps its online link next to the venue.
+	hybrid := r.Form.Get("hybrid") == "1" && e.LocationURL != ""
+
+	// An event that already had a place is being moved, so attendees need to
+	// know.
+	moved := e.VenueID != nil || (e.LocationURL != "" && !hybrid)
+
+	// Events take place in the timezone of their venue. A new event keeps the
+	// local times the host entered, a moved one keeps its instant.
+	timezone, err := db.GetCityTimezone(a.DB, cityID)
+	if err != nil {
+		slog.Error("Failed to get timezone of venue city.", "cityID", cityID, "err", err)
+	} else if timezone != "" && timezone != e.Timezone {
+		err = e.SetTimezone(timezone, !moved)
+		if err != nil {
+			slog.Error("Failed to set event timezone.", "eventID", e.ID, "timezone", timezone, "err", err)
+		}
+	}
+
+	v.DB = a.DB
 	e.Venue = v
 	e.VenueID = &v.ID
-	e.Save()
+	if !hybrid {
+		e.LocationURL = ""
+	}
+	err = e.Save()
+	if err != nil {
+
+		slog.Error("Failed to save venue to event.", "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save venue.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if moved {
+		a.notifyEventChanged(e, u, "venue")
+	}
+
+	// Without an attendee limit, the capacity of the new venue applies, and
+	// a hybrid event gets a second track
+	a.promoteWaitlist(e)
+
+	// Occurrences of a recurring event share their place with the series.
+	if e.SeriesID != nil && !e.IsOverride {
+
+		err = a.seriesApplyPlace(e, u, moved)
+		if err != nil {
+			slog.Error("Failed to apply place to series.", "seriesID", *e.SeriesID, "err", err)
+		}
+	}
 
 	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
 	return
@@ -136,8 +206,8 @@ func (a *app) venueWWWPost(w http.ResponseWriter, r *http.Request) {
 	// middlewareEvent ensures we have an Event
 	e := r.Context().Value("event").(*db.Event)
 
-	// Only the owner of the group can add/edit an event
-	if !e.TheGroup.HasCreate(u.ID) {
+	// Group hosts and event hosts can change the venue
+	if !e.Can(u.ID, db.EventPermVenue) {
 
 		session.AddFlash(framework.Flash{
 			framework.FlashFail,
@@ -166,8 +236,66 @@ func (a *app) venueWWWPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	onlineLimit := 0
+	if limit := r.Form.Get("online-limit"); limit != "" {
+
+		onlineLimit, err = strconv.Atoi(limit)
+		if err != nil || onlineLimit < 0 {
+
+			slog.Error("Online limit is not valid.", "online-limit", limit)
+			session.AddFlash(framework.Flash{
+				framework.FlashFail,
+				"Online attendee limit was invalid.",
+			})
+
+			session.Save(r, w)
+			http.Redirect(w, r, "/events/"+e.IDString()+"/new-venue#www", http.StatusFound)
+			return
+		}
+	}
+
+	// A hybrid event keeps its venue next to the online link.
+	hybrid := r.Form.Get("hybrid") == "1" && e.VenueID != nil
+
+	// An event that already had a place is being moved, so attendees need to
+	// know.
+	moved := (e.VenueID != nil && !hybrid) || (e.LocationURL != "" && e.LocationURL != urlWWW.String())
+
+	if !hybrid {
+		e.Venue = nil
+		e.VenueID = nil
+	}
 	e.LocationURL = urlWWW.String()
-	e.Save()
+	e.OnlineLimit = onlineLimit
+	err = e.Save()
+	if err != nil {
+
+		slog.Error("Failed to save URL to event.", "err", err)
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"Failed to save URL.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
+		return
+	}
+
+	if moved {
+		a.notifyEventChanged(e, u, "location")
+	}
+
+	// The online track can have room for the waitlist
+	a.promoteWaitlist(e)
+
+	// Occurrences of a recurring event share their place with the series.
+	if e.SeriesID != nil && !e.IsOverride {
+
+		err = a.seriesApplyPlace(e, u, moved)
+		if err != nil {
+			slog.Error("Failed to apply place to series.", "seriesID", *e.SeriesID, "err", err)
+		}
+	}
 
 	http.Redirect(w, r, "/events/"+e.IDString(), http.StatusFound)
 	return
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+/*
+ * The group widget lists the upcoming events of a group on other websites,
+ * in an iframe. The widget routes don't use the session at all, so that a
+ * logged in owner can't leak their private group onto a website and so that
+ * the responses can be cached by anyone.
+ */
+
+// widgetMaxEvents is the most events a widget can list.
+const widgetMaxEvents = 20
+
+// widgetJS inserts the iframe of the widget after its script tag. The iframe
+// is resized to its content with the height the widget posts.
+const widgetJS = `(function () {
+	var script = document.currentScript;
+	var params = new URLSearchParams({
+		theme: script.dataset.theme || "light",
+		limit: script.dataset.limit || "5"
+	});
+
+	var iframe = document.createElement("iframe");
+	iframe.src = new URL("/widgets/groups/" + GROUP_ID + "?" + params, script.src).href;
+	iframe.title = TITLE;
+	iframe.loading = "lazy";
+	iframe.style.cssText = "width: 100%; height: 400px; border: 0;";
+	script.parentNode.insertBefore(iframe, script.nextSibling);
+
+	window.addEventListener("message", function (e) {
+		if (e.source === iframe.contentWindow && e.data && e.data.eventhuntWidget) {
+			iframe.style.height = e.data.height + "px";
+		}
+	});
+})();
+`
+
+/*
+ * The widget of a group, meant to be shown in an iframe. The theme query
+ * parameter is light or dark and limit is how many events are listed.
+ *
+ * Path: /widgets/groups/{group-id}
+ */
+func (a *app) widgetGet(w http.ResponseWriter, r *http.Request) {
+
+	g := a.widgetGroup(w, r)
+	if g == nil {
+		return
+	}
+
+	theme := r.URL.Query().Get("theme")
+	if theme != "dark" {
+		theme = "light"
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 5
+	}
+	limit = min(limit, widgetMaxEvents)
+
+	events, err := db.GetEventsByGroup(a.DB, g.ID, false, uint8(limit))
+	if err != nil {
+		slog.Error("Failed to get events for widget.", "groupID", g.ID, "err", err)
+		http.Error(w, "Failed to load events.", http.StatusInternalServerError)
+		return
+	}
+
+	tpl, err := template.ParseFiles(
+		a.ThemePath()+"sections/widgets/group.go.html",
+		a.ThemePath()+"base/widget.html",
+	)
+	if err != nil {
+		slog.Error("Theme files are missing.", "err", err)
+		http.Error(w, "Failed to load the widget.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// any website can show the widget in an iframe
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+
+	err = tpl.ExecuteTemplate(w, "base", map[string]interface{}{
+		"AppName": AppName,
+		"Group":   g,
+		"Events":  events,
+		"Theme":   theme,
+	})
+	if err != nil {
+		slog.Error("Failed to render widget.", "groupID", g.ID, "err", err)
+	}
+}
+
+/*
+ * The script that adds the widget of a group to a website.
+ *
+ * Path: /widgets/groups/{group-id}.js
+ */
+func (a *app) widgetJSGet(w http.ResponseWriter, r *http.Request) {
+
+	g := a.widgetGroup(w, r)
+	if g == nil {
+		return
+	}
+
+	js := strings.NewReplacer(
+		"GROUP_ID", g.IDString(),
+		"TITLE", strconv.Quote("Upcoming events of "+g.Name),
+	).Replace(widgetJS)
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write([]byte(js))
+}
+
+/*
+ * Shows group hosts how to add the widget to their website.
+ *
+ * Path: /groups/{group-id}/embed
+ */
+func (a *app) widgetEmbedGet(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := store.Get(r, "login")
+
+	// middlewareLIO ensures we have a user
+	u := r.Context().Value("user").(*db.User)
+	// middlewareGroup ensures we have a Group
+	g := r.Context().Value("group").(*db.Group)
+
+	if !g.HasCreate(u.ID) {
+
+		session.AddFlash(framework.Flash{
+			framework.FlashFail,
+			"You don't have permission to embed this group.",
+		})
+
+		session.Save(r, w)
+		http.Redirect(w, r, "/groups/"+g.IDString(), http.StatusFound)
+		return
+	}
+
+	renderPage(a, "groups/embed", w, r, map[string]interface{}{
+		"User":    u,
+		"Group":   g,
+		"BaseURL": baseURL(),
+	})
+}
+
+/*
+ * widgetGroup returns the group of a widget request. Private and archived
+ * groups are answered the same way as groups that don't exist, whoever asks,
+ * and nil is returned.
+ */
+func (a *app) widgetGroup(w http.ResponseWriter, r *http.Request) *db.Group {
+
+	groupID, err := strconv.ParseUint(chi.URLParam(r, "group-id"), 10, 64)
+
+	var g *db.Group
+	if err == nil {
+		g, err = db.GetGroupByID(a.DB, groupID)
+	}
+	if err != nil || g.IsPrivate || g.IsArchived() {
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		http.Error(w, "Group not found.", http.StatusNotFound)
+		return nil
+	}
+
+	return g
+}
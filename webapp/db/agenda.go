@@ -0,0 +1,467 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_AGENDA_SESSIONS = "agenda_sessions"
+const DB_TABLE_SPEAKERS = "speakers"
+
+// ErrAgendaOutsideEvent is returned when an event is rescheduled in a way
+// that would leave sessions of its agenda outside of it.
+var ErrAgendaOutsideEvent = errors.New("Some sessions of the agenda wouldn't take place during the event anymore, change them first.")
+
+/*
+ * AgendaSession is a part of an event's agenda, such as a talk or a
+ * workshop. Sessions take place within the event and are listed in the order
+ * of Position, which hosts choose, so that parallel sessions can be grouped.
+ */
+type AgendaSession struct {
+	framework.BaseModel
+	EventID   uint64     `db:"event_id" validate:"required"`
+	Title     string     `db:"title" validate:"required,max=200"`
+	Abstract  string     `db:"abstract" validate:"max=10000"`
+	StartTime time.Time  `db:"start_time" validate:"required"`
+	EndTime   time.Time  `db:"end_time" validate:"required,gtfield=StartTime"`
+	Room      string     `db:"room" validate:"max=100"`
+	Position  int        `db:"position"`
+	Speakers  []*Speaker `db:"-"`
+}
+
+/*
+ * Speaker gives an agenda session. When the speaker is an EventHunt user,
+ * UserID links to them.
+ */
+type Speaker struct {
+	framework.BaseModel
+	SessionID uint64  `db:"session_id"`
+	Name      string  `db:"name" validate:"required,max=100"`
+	UserID    *uint64 `db:"user_id"`
+	TheUser   *User   `db:"-"`
+	Position  int     `db:"position"`
+}
+
+/*
+ * Delete removes the session from the agenda along with its speakers.
+ */
+func (s *AgendaSession) Delete() error {
+
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM `+s.table()+` WHERE id=$1`, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = agendaChanged(tx, s.EventID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * SpeakerNames returns the names of the speakers separated by commas.
+ */
+func (s *AgendaSession) SpeakerNames() string {
+
+	var names []string
+	for _, speaker := range s.Speakers {
+		names = append(names, speaker.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (s *AgendaSession) primaryKey() string { return "id" }
+
+/*
+ * table returns the table name used in the database.
+ */
+func (s *AgendaSession) table() string { return DB_TABLE_AGENDA_SESSIONS }
+
+/*
+ * Agenda returns the sessions of the event, in order, with their speakers.
+ */
+func (e *Event) Agenda() []*AgendaSession {
+
+	sessions, err := GetAgendaByEvent(e.DB, e.ID)
+	if err != nil {
+		return nil
+	}
+
+	return sessions
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * NewAgendaSession adds a session at the end of the agenda of an event. Each
+ * speaker is a name, or an EventHunt username or email address after an @ to
+ * link the user.
+ */
+func NewAgendaSession(e *Event, title, abstract string, start, end time.Time, room string, speakers []string) (*AgendaSession, error) {
+
+	s := &AgendaSession{
+		EventID:   e.ID,
+		Title:     strings.TrimSpace(title),
+		Abstract:  strings.TrimSpace(abstract),
+		StartTime: start.UTC(),
+		EndTime:   end.UTC(),
+		Room:      strings.TrimSpace(room),
+	}
+
+	err := validate.Struct(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.StartTime.Before(e.StartTime) || s.EndTime.After(e.EndTime) {
+		return nil, errors.New("Sessions have to take place during the event.")
+	}
+
+	for _, value := range speakers {
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		speaker := &Speaker{Name: value}
+
+		if strings.HasPrefix(value, "@") {
+
+			u, err := GetUserByUsernameOrEmail(e.DB, value[1:])
+			if err != nil {
+				return nil, errors.New("There's no user \"" + value + "\".")
+			}
+
+			speaker.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			if speaker.Name == "" {
+				speaker.Name = u.Username
+			}
+			speaker.UserID = &u.ID
+			speaker.TheUser = u
+		}
+
+		err = validate.Struct(speaker)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Speakers = append(s.Speakers, speaker)
+	}
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertAgendaSession(tx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = agendaChanged(tx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.DB = e.DB
+
+	return s, tx.Commit(ctx)
+}
+
+/*
+ * GetAgendaSessionByID returns the agenda session with the provided ID,
+ * without its speakers.
+ */
+func GetAgendaSessionByID(db *pgxpool.Pool, id uint64) (*AgendaSession, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_AGENDA_SESSIONS+` WHERE id=$1`, id)
+
+	s, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[AgendaSession])
+	if err != nil {
+		return nil, err
+	}
+	s.DB = db
+
+	return s, nil
+}
+
+/*
+ * GetAgendaByEvent returns the sessions of an event in order, with their
+ * speakers.
+ */
+func GetAgendaByEvent(db *pgxpool.Pool, eventID uint64) ([]*AgendaSession, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_AGENDA_SESSIONS+` WHERE event_id=$1 ORDER BY position, id`, eventID)
+
+	sessions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[AgendaSession])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	q := `SELECT sp.* FROM ` + DB_TABLE_SPEAKERS + ` sp
+		JOIN ` + DB_TABLE_AGENDA_SESSIONS + ` s ON s.id=sp.session_id
+		WHERE s.event_id=$1 ORDER BY sp.position, sp.id`
+	rows, _ = db.Query(context.Background(), q, eventID)
+
+	speakers, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Speaker])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+
+		s.DB = db
+
+		for _, speaker := range speakers {
+
+			if speaker.SessionID != s.ID {
+				continue
+			}
+
+			if speaker.UserID != nil {
+				speaker.TheUser, err = GetUserByID(db, *speaker.UserID)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			s.Speakers = append(s.Speakers, speaker)
+		}
+	}
+
+	return sessions, nil
+}
+
+/*
+ * MoveAgendaSession swaps a session with the one before (up) or after it on
+ * the agenda of the event.
+ */
+func MoveAgendaSession(e *Event, sessionID uint64, up bool) error {
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	var position int
+	err = tx.QueryRow(ctx, `SELECT position FROM `+DB_TABLE_AGENDA_SESSIONS+` WHERE id=$1 AND event_id=$2`, sessionID, e.ID).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	q := `SELECT id, position FROM ` + DB_TABLE_AGENDA_SESSIONS + ` WHERE event_id=$1 AND position < $2 ORDER BY position DESC LIMIT 1`
+	if !up {
+		q = `SELECT id, position FROM ` + DB_TABLE_AGENDA_SESSIONS + ` WHERE event_id=$1 AND position > $2 ORDER BY position ASC LIMIT 1`
+	}
+
+	var otherID uint64
+	var otherPosition int
+	err = tx.QueryRow(ctx, q, e.ID, position).Scan(&otherID, &otherPosition)
+	if err == pgx.ErrNoRows {
+		// already first or last
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	q = `UPDATE ` + DB_TABLE_AGENDA_SESSIONS + ` SET position=$1, updated_time=CURRENT_TIMESTAMP WHERE id=$2`
+
+	_, err = tx.Exec(ctx, q, otherPosition, sessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, q, position, otherID)
+	if err != nil {
+		return err
+	}
+
+	err = agendaChanged(tx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * CopyAgenda adds the sessions of an event, with their speakers, to another
+ * one. The sessions are moved by shift, the time between the start of the two
+ * events.
+ */
+func CopyAgenda(db *pgxpool.Pool, fromEventID, toEventID uint64, shift time.Duration) error {
+
+	sessions, err := GetAgendaByEvent(db, fromEventID)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, s := range sessions {
+
+		s.EventID = toEventID
+		s.StartTime = s.StartTime.Add(shift)
+		s.EndTime = s.EndTime.Add(shift)
+
+		err = insertAgendaSession(tx, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * shiftAgenda moves the sessions of an event by as much as its start time is
+ * changing, as CopyAgenda does for copies. It has to run before the event is
+ * saved. Changes that would leave a session outside of the event are refused
+ * with ErrAgendaOutsideEvent.
+ */
+func shiftAgenda(tx pgx.Tx, e *Event) error {
+
+	ctx := context.Background()
+	args := pgx.NamedArgs{
+		"id":        e.ID,
+		"startTime": e.StartTime.UTC(),
+		"endTime":   e.EndTime.UTC(),
+	}
+
+	q := `UPDATE ` + DB_TABLE_AGENDA_SESSIONS + ` s
+		SET start_time=s.start_time + (@startTime::timestamptz - ev.start_time),
+			end_time=s.end_time + (@startTime::timestamptz - ev.start_time),
+			updated_time=CURRENT_TIMESTAMP
+		FROM ` + DB_TABLE_EVENT + ` ev
+		WHERE ev.id=s.event_id AND ev.id=@id AND ev.start_time <> @startTime::timestamptz`
+	_, err := tx.Exec(ctx, q, args)
+	if err != nil {
+		return err
+	}
+
+	// only checked when the times change, so that an agenda that's already
+	// off doesn't stop the event from being cancelled for example
+	var outside bool
+	q = `SELECT EXISTS (SELECT 1 FROM ` + DB_TABLE_AGENDA_SESSIONS + ` s
+		JOIN ` + DB_TABLE_EVENT + ` ev ON ev.id=s.event_id
+		WHERE ev.id=@id
+			AND (ev.start_time, ev.end_time) IS DISTINCT FROM (@startTime::timestamptz, @endTime::timestamptz)
+			AND (s.start_time < @startTime::timestamptz OR s.end_time > @endTime::timestamptz))`
+	err = tx.QueryRow(ctx, q, args).Scan(&outside)
+	if err != nil {
+		return err
+	}
+
+	if outside {
+		return ErrAgendaOutsideEvent
+	}
+
+	return nil
+}
+
+/*
+ * insertAgendaSession adds a session, with its speakers, at the end of the
+ * agenda of its event.
+ */
+func insertAgendaSession(tx pgx.Tx, s *AgendaSession) error {
+
+	ctx := context.Background()
+
+	q := `INSERT INTO ` + DB_TABLE_AGENDA_SESSIONS + ` (event_id, title, abstract, start_time, end_time, room, position)
+		VALUES (@eventID, @title, @abstract, @startTime, @endTime, @room,
+			(SELECT COALESCE(MAX(position), 0) + 1 FROM ` + DB_TABLE_AGENDA_SESSIONS + ` WHERE event_id=@eventID))
+		RETURNING id, position`
+	err := tx.QueryRow(ctx, q, pgx.NamedArgs{
+		"eventID":   s.EventID,
+		"title":     s.Title,
+		"abstract":  s.Abstract,
+		"startTime": s.StartTime.UTC(),
+		"endTime":   s.EndTime.UTC(),
+		"room":      s.Room,
+	}).Scan(&s.ID, &s.Position)
+	if err != nil {
+		return err
+	}
+
+	for i, speaker := range s.Speakers {
+
+		speaker.SessionID = s.ID
+		speaker.Position = i + 1
+
+		q = `INSERT INTO ` + DB_TABLE_SPEAKERS + ` (session_id, name, user_id, position)
+			VALUES (@sessionID, @name, @userID, @position) RETURNING id`
+		err = tx.QueryRow(ctx, q, pgx.NamedArgs{
+			"sessionID": speaker.SessionID,
+			"name":      speaker.Name,
+			"userID":    speaker.UserID,
+			"position":  speaker.Position,
+		}).Scan(&speaker.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * agendaChanged bumps the sequence of the event, since the agenda is part of
+ * its description in calendar apps.
+ */
+func agendaChanged(tx pgx.Tx, eventID uint64) error {
+
+	q := `UPDATE ` + DB_TABLE_EVENT + ` SET sequence=sequence+1, updated_time=CURRENT_TIMESTAMP WHERE id=$1`
+	_, err := tx.Exec(context.Background(), q, eventID)
+
+	return err
+}
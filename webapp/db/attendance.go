@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Attendance summarizes who said they would come compared to who actually
+ * showed up, based on rsvps.actual. Only attendees are counted, not hosts or
+ * crew.
+ */
+type Attendance struct {
+	// Attendees that had a spot. Walk-ins and the waitlist aren't included.
+	Expected int `db:"expected"`
+	// Guests the expected attendees said they would bring.
+	Guests   int `db:"guests"`
+	InPerson int `db:"in_person"`
+	Online   int `db:"online"`
+	NoShows  int `db:"no_shows"`
+	WalkIns  int `db:"walk_ins"`
+}
+
+/*
+ * MemberAttendance is the Attendance of a single member over the past
+ * events of a group.
+ */
+type MemberAttendance struct {
+	Attendance
+	UserID  uint64 `db:"user_id"`
+	TheUser *User  `db:"-"`
+}
+
+/*
+ * Attended returns how many people showed up, in any form.
+ */
+func (a Attendance) Attended() int {
+	return a.InPerson + a.Online
+}
+
+/*
+ * AttendanceRate returns the percentage of expected attendees that showed up.
+ * Walk-ins don't count towards it.
+ */
+func (a Attendance) AttendanceRate() int {
+
+	if a.Expected == 0 {
+		return 0
+	}
+
+	return (a.Attended() - a.WalkIns) * 100 / a.Expected
+}
+
+/*
+ * NoShowRate returns the percentage of expected attendees that didn't show up.
+ */
+func (a Attendance) NoShowRate() int {
+
+	if a.Expected == 0 {
+		return 0
+	}
+
+	return a.NoShows * 100 / a.Expected
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+// attendanceColumns aggregates rsvps rows into the columns of Attendance.
+const attendanceColumns = `
+	count(*) FILTER (WHERE r.intent IN ('yes', 'in-person', 'online') AND r.waitlist_position IS NULL AND NOT r.walk_in) AS expected,
+	COALESCE(sum(r.guests) FILTER (WHERE r.intent IN ('yes', 'in-person', 'online') AND r.waitlist_position IS NULL AND NOT r.walk_in), 0) AS guests,
+	count(*) FILTER (WHERE r.actual='in-person') AS in_person,
+	count(*) FILTER (WHERE r.actual='online') AS online,
+	count(*) FILTER (WHERE r.actual='no') AS no_shows,
+	count(*) FILTER (WHERE r.walk_in) AS walk_ins`
+
+/*
+ * CheckIn records whether a user actually attended an event. A nil actual
+ * clears it. Users without an RSVP are added as walk-ins.
+ */
+func CheckIn(e *Event, userID uint64, actual *RSVPStatus) error {
+
+	q := `INSERT INTO ` + DB_TABLE_RSVP + ` (event_id, user_id, intent, role, actual, walk_in)
+		VALUES (@eventID, @userID, 'yes', 'attendee', @actual, true)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET actual=EXCLUDED.actual,
+			updated_time=CURRENT_TIMESTAMP`
+	_, err := e.DB.Exec(context.Background(), q, pgx.NamedArgs{
+		"eventID": e.ID,
+		"userID":  userID,
+		"actual":  actual,
+	})
+
+	return err
+}
+
+/*
+ * GetAttendanceByEvent returns the attendance figures of an event.
+ */
+func GetAttendanceByEvent(db *pgxpool.Pool, eventID uint64) (Attendance, error) {
+
+	q := `SELECT ` + attendanceColumns + ` FROM ` + DB_TABLE_RSVP + ` r
+		WHERE r.event_id=@eventID AND r.role='attendee'`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+	})
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Attendance])
+}
+
+/*
+ * GetAttendanceByGroup returns the attendance figures of every member that
+ * RSVP'd to a past event of the group, the most no-shows first.
+ */
+func GetAttendanceByGroup(db *pgxpool.Pool, groupID uint64) ([]*MemberAttendance, error) {
+
+	q := `SELECT r.user_id, ` + attendanceColumns + ` FROM ` + DB_TABLE_RSVP + ` r
+		JOIN ` + DB_TABLE_EVENT + ` e ON e.id=r.event_id
+		WHERE e.group_id=@groupID AND e.start_time < CURRENT_TIMESTAMP AND r.role='attendee'
+		GROUP BY r.user_id
+		ORDER BY no_shows DESC, expected DESC`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"groupID": groupID,
+	})
+
+	members, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[MemberAttendance])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range members {
+
+		m.TheUser, err = GetUserByID(db, m.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return members, nil
+}
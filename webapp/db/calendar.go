@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_CALENDAR_TOKENS = "calendar_tokens"
+
+/*
+ * CalendarToken is the secret part of a user's personal iCalendar feed URL.
+ * Calendar apps can't log in so whoever has the token can read the feed.
+ * Each user has at most one, regenerating it invalidates the old URL.
+ */
+type CalendarToken struct {
+	framework.BaseModel
+	UserID uint64 `db:"user_id" validate:"required"`
+	Value  string `db:"the_value" validate:"required"`
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (ct *CalendarToken) primaryKey() string { return "id" }
+
+/*
+ * table returns the table name used in the database.
+ */
+func (ct *CalendarToken) table() string { return DB_TABLE_CALENDAR_TOKENS }
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * Internal init function.
+ */
+func initCalendarToken(db *pgxpool.Pool) *CalendarToken {
+
+	ct := new(CalendarToken)
+	ct.DB = db
+
+	return ct
+}
+
+/*
+ * NewCalendarToken generates a new feed token for the user. An existing token
+ * is replaced.
+ */
+func NewCalendarToken(u *User) (*CalendarToken, error) {
+
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	ct := initCalendarToken(u.DB)
+	ct.UserID = u.ID
+	ct.Value = base64.RawURLEncoding.EncodeToString(b)
+
+	err = validate.Struct(ct)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO ` + ct.table() + ` (user_id, the_value) VALUES (@userID, @value)
+		ON CONFLICT (user_id) DO UPDATE SET the_value=EXCLUDED.the_value, updated_time=CURRENT_TIMESTAMP
+		RETURNING *`
+	rows, _ := ct.DB.Query(context.Background(), q, pgx.NamedArgs{
+		"userID": ct.UserID,
+		"value":  ct.Value,
+	})
+
+	ct, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[CalendarToken])
+	if err != nil {
+		return nil, err
+	}
+	ct.DB = u.DB
+
+	return ct, nil
+}
+
+/*
+ * GetCalendarTokenByQuery returns a single CalendarToken from the DB based on
+ * the provided query.
+ */
+func GetCalendarTokenByQuery(db *pgxpool.Pool, q string, args any) (*CalendarToken, error) {
+
+	rows, _ := db.Query(context.Background(), q, args)
+	ct, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[CalendarToken])
+	if err != nil {
+		return nil, err
+	}
+	ct.DB = db
+
+	return ct, nil
+}
+
+/*
+ * GetCalendarTokenByUser returns the feed token of a user, if they generated
+ * one.
+ */
+func GetCalendarTokenByUser(db *pgxpool.Pool, userID uint64) (*CalendarToken, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_CALENDAR_TOKENS + ` WHERE user_id=@userID`
+
+	return GetCalendarTokenByQuery(db, q, pgx.NamedArgs{
+		"userID": userID,
+	})
+}
+
+/*
+ * GetCalendarTokenByValue returns the feed token matching value.
+ */
+func GetCalendarTokenByValue(db *pgxpool.Pool, value string) (*CalendarToken, error) {
+
+	if value == "" {
+		return nil, errors.New("Calendar token cannot be blank.")
+	}
+
+	q := `SELECT * FROM ` + DB_TABLE_CALENDAR_TOKENS + ` WHERE the_value=@value`
+
+	return GetCalendarTokenByQuery(db, q, pgx.NamedArgs{
+		"value": value,
+	})
+}
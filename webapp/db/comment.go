@@ -0,0 +1,306 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_COMMENTS = "comments"
+
+/*
+ * Comment is a message on an event page. A comment without a parent starts a
+ * thread, replies point to the comment they answer and to the root of their
+ * thread.
+ */
+type Comment struct {
+	framework.BaseModel
+	EventID  uint64  `db:"event_id" validate:"required"`
+	UserID   uint64  `db:"user_id" validate:"required"`
+	TheUser  *User   `db:"-"`
+	ParentID *uint64 `db:"parent_id"`
+	RootID   *uint64 `db:"root_id"`
+	Body     string  `db:"body" validate:"required,max=5000"`
+	// Pinned threads are shown first. Hidden comments are only shown to
+	// hosts, their replies stay visible.
+	IsPinned bool `db:"is_pinned"`
+	IsHidden bool `db:"is_hidden"`
+	// Depth is how deep the comment is in its thread, 0 for the root. It's
+	// set by GetCommentsByEvent.
+	Depth int `db:"-"`
+}
+
+/*
+ * Delete removes the comment along with its replies.
+ */
+func (c *Comment) Delete() error {
+
+	_, err := c.DB.Exec(context.Background(), `DELETE FROM `+c.table()+` WHERE id=$1`, c.ID)
+
+	return err
+}
+
+/*
+ * Hide hides the comment from everyone but hosts, or shows it again.
+ */
+func (c *Comment) Hide(hidden bool) error {
+
+	c.IsHidden = hidden
+
+	return c.Save()
+}
+
+/*
+ * Indent returns how much the comment is indented on the page, in em. Deep
+ * threads stop indenting after a few levels.
+ */
+func (c *Comment) Indent() int {
+	return min(c.Depth, 5) * 2
+}
+
+/*
+ * Pin moves the thread to the top of the event page, or unpins it. Only the
+ * root of a thread can be pinned.
+ */
+func (c *Comment) Pin(pinned bool) error {
+
+	if c.ParentID != nil {
+		return errors.New("Only the first comment of a thread can be pinned.")
+	}
+
+	c.IsPinned = pinned
+
+	return c.Save()
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (c *Comment) primaryKey() string { return "id" }
+
+/*
+ * Save serializes the struct to the database. Only the moderation flags can
+ * change.
+ */
+func (c *Comment) Save() error {
+
+	q := `UPDATE ` + c.table() + ` SET is_pinned=@isPinned, is_hidden=@isHidden,
+		updated_time=CURRENT_TIMESTAMP WHERE ` + c.primaryKey() + `=@id`
+	_, err := c.DB.Exec(context.Background(), q, pgx.NamedArgs{
+		"isPinned": c.IsPinned,
+		"isHidden": c.IsHidden,
+		"id":       c.ID,
+	})
+
+	return err
+}
+
+/*
+ * table returns the table name used in the database.
+ */
+func (c *Comment) table() string { return DB_TABLE_COMMENTS }
+
+/*
+ * ThreadID returns the ID of the root comment of the thread.
+ */
+func (c *Comment) ThreadID() uint64 {
+
+	if c.RootID != nil {
+		return *c.RootID
+	}
+
+	return c.ID
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * Internal init function.
+ */
+func initComment(db *pgxpool.Pool) *Comment {
+
+	c := new(Comment)
+	c.DB = db
+
+	return c
+}
+
+/*
+ * NewComment posts a comment on an event. With a parent, it's a reply in the
+ * parent's thread.
+ */
+func NewComment(u *User, e *Event, parent *Comment, body string) (*Comment, error) {
+
+	c := initComment(u.DB)
+	c.EventID = e.ID
+	c.UserID = u.ID
+	c.Body = body
+
+	if parent != nil {
+
+		if parent.EventID != e.ID {
+			return nil, errors.New("Cannot reply to a comment of another event.")
+		}
+
+		rootID := parent.ThreadID()
+		c.ParentID = &parent.ID
+		c.RootID = &rootID
+	}
+
+	err := validate.Struct(c)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO ` + c.table() + ` (event_id, user_id, parent_id, root_id, body)
+		VALUES (@eventID, @userID, @parentID, @rootID, @body) RETURNING *`
+	rows, _ := c.DB.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID":  c.EventID,
+		"userID":   c.UserID,
+		"parentID": c.ParentID,
+		"rootID":   c.RootID,
+		"body":     c.Body,
+	})
+
+	c, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Comment])
+	if err != nil {
+		return nil, err
+	}
+	c.DB = u.DB
+	c.TheUser = u
+
+	return c, nil
+}
+
+/*
+ * GetCommentsByQuery returns a slice of Comment based on the SQL query
+ * provided.
+ */
+func GetCommentsByQuery(db *pgxpool.Pool, q string, args any) ([]*Comment, error) {
+
+	rows, _ := db.Query(context.Background(), q, args)
+	comments, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Comment])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comments {
+
+		c.DB = db
+
+		c.TheUser, err = GetUserByID(db, c.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return comments, nil
+}
+
+/*
+ * GetCommentByID returns the comment with the provided ID.
+ */
+func GetCommentByID(db *pgxpool.Pool, id uint64) (*Comment, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_COMMENTS + ` WHERE id=@id`
+
+	comments, err := GetCommentsByQuery(db, q, pgx.NamedArgs{
+		"id": id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(comments) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return comments[0], nil
+}
+
+/*
+ * GetCommentsByEvent returns the comments of an event in reading order. Pinned
+ * threads come first, then threads from the oldest. Each reply follows the
+ * comment it answers with its Depth set.
+ */
+func GetCommentsByEvent(db *pgxpool.Pool, eventID uint64) ([]*Comment, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_COMMENTS + ` WHERE event_id=@eventID ORDER BY created_time ASC, id ASC`
+
+	comments, err := GetCommentsByQuery(db, q, pgx.NamedArgs{
+		"eventID": eventID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*Comment
+	replies := make(map[uint64][]*Comment)
+
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			replies[*c.ParentID] = append(replies[*c.ParentID], c)
+		}
+	}
+
+	sort.SliceStable(roots, func(i, j int) bool {
+		return roots[i].IsPinned && !roots[j].IsPinned
+	})
+
+	ordered := make([]*Comment, 0, len(comments))
+
+	var walk func(c *Comment, depth int)
+	walk = func(c *Comment, depth int) {
+
+		c.Depth = depth
+		ordered = append(ordered, c)
+
+		for _, reply := range replies[c.ID] {
+			walk(reply, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	return ordered, nil
+}
+
+/*
+ * GetThreadParticipants returns everyone that commented in a thread.
+ */
+func GetThreadParticipants(db *pgxpool.Pool, rootID uint64) ([]*User, error) {
+
+	q := `SELECT DISTINCT user_id FROM ` + DB_TABLE_COMMENTS + ` WHERE id=@rootID OR root_id=@rootID`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"rootID": rootID,
+	})
+
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[uint64])
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*User
+	for _, id := range userIDs {
+
+		u, err := GetUserByID(db, id)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
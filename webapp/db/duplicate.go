@@ -0,0 +1,141 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// maxShifts stops ShiftToFuture from looping forever on a tiny shift.
+const maxShifts = 1000
+
+/*
+ * Shift is an amount of time events are moved by when duplicated, such as one
+ * week or one month.
+ */
+type Shift struct {
+	Count int
+	// "day", "week", "month" or "year"
+	Unit string
+}
+
+/*
+ * Apply returns t moved by the shift. Months and years keep the day of the
+ * month, or the last day of shorter months, and the time of day.
+ */
+func (s Shift) Apply(t time.Time) (time.Time, error) {
+
+	if s.Count <= 0 {
+		return t, errors.New("The shift must be at least 1.")
+	}
+
+	switch s.Unit {
+	case "day":
+		return t.AddDate(0, 0, s.Count), nil
+	case "week":
+		return t.AddDate(0, 0, 7*s.Count), nil
+	case "month":
+		return addMonths(t, s.Count), nil
+	case "year":
+		return addMonths(t, 12*s.Count), nil
+	}
+
+	return t, errors.New("Unknown shift unit: " + s.Unit)
+}
+
+/*
+ * ShiftToFuture applies the shift to t, and again, until the result is after
+ * now. Duplicating a past event therefore always lands on an upcoming date
+ * with the same rhythm, e.g. the same weekday for weekly shifts.
+ */
+func (s Shift) ShiftToFuture(t, now time.Time) (time.Time, error) {
+
+	// Skip ahead using the longest a unit can be, so this never overshoots
+	// the first upcoming date.
+	longest := map[string]time.Duration{
+		"day":   25 * time.Hour,
+		"week":  7 * 25 * time.Hour,
+		"month": 31 * 25 * time.Hour,
+		"year":  366 * 25 * time.Hour,
+	}[s.Unit]
+
+	first := 1
+	if longest > 0 && s.Count > 0 && now.After(t) {
+		first = max(1, int(now.Sub(t)/(longest*time.Duration(s.Count))))
+	}
+
+	for i := first; i < first+maxShifts; i++ {
+
+		shifted, err := (Shift{s.Count * i, s.Unit}).Apply(t)
+		if err != nil {
+			return t, err
+		}
+
+		if shifted.After(now) {
+			return shifted, nil
+		}
+	}
+
+	return t, errors.New("The shift is too small to reach an upcoming date.")
+}
+
+/*
+ * Duplicate copies the event into a new draft of the same group, starting at
+ * start and lasting as long on the wall clock of its timezone. The name,
+ * summary, description, location, timezone, attendee and guest limits,
+ * registration questions, ticket types and agenda are copied. RSVPs, answers,
+ * orders, comments and the series aren't.
+ */
+func (e *Event) Duplicate(u *User, start time.Time) (*Event, error) {
+
+	end := WallClockEnd(start, e.StartTime, e.EndTime, e.Location())
+
+	dup, err := NewEvent(u, e.GroupID, e.Name, start, end, e.Timezone, e.Summary)
+	if err != nil {
+		return nil, err
+	}
+
+	dup.Description = e.Description
+	dup.WebURL = e.WebURL
+	dup.VenueID = e.VenueID
+	dup.LocationURL = e.LocationURL
+	dup.AttendeeLimit = e.AttendeeLimit
+	dup.OnlineLimit = e.OnlineLimit
+	dup.GuestLimit = e.GuestLimit
+
+	err = dup.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	err = CopyQuestions(e.DB, e.ID, dup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CopyTicketTypes(e.DB, e.ID, dup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CopyAgenda(e.DB, e.ID, dup.ID, dup.StartTime.Sub(e.StartTime))
+	if err != nil {
+		return nil, err
+	}
+
+	dup.TheGroup = e.TheGroup
+	dup.Venue = e.Venue
+
+	return dup, nil
+}
+
+/*
+ * addMonths adds months to t without overflowing into the next month, so that
+ * January 31 plus one month is the last day of February.
+ */
+func addMonths(t time.Time, months int) time.Time {
+
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
@@ -13,6 +13,15 @@ import (
 
 const DB_TABLE_EVENT = "events"
 
+type EventStatus string
+
+const (
+	EventDraft     EventStatus = "draft"
+	EventPublished EventStatus = "published"
+	EventCancelled EventStatus = "cancelled"
+	EventPostponed EventStatus = "postponed"
+)
+
 /*
  * event represents a physical event in the real-world.
  */
@@ -21,8 +30,8 @@ type Event struct {
 	GroupID       uint64    `db:"group_id" validate:"required"`
 	TheGroup      *Group    `db:"-"`
 	Name          string    `db:"name" validate:"required,min=3,max=80"`
-	StartTime     time.Time `db:"start_time"`
-	EndTime       time.Time `db:"end_time"`
+	StartTime     time.Time `db:"start_time" validate:"required"`
+	EndTime       time.Time `db:"end_time" validate:"required,gtfield=StartTime"`
 	Summary       string    `db:"summary"`
 	Description   string    `db:"description"`
 	WebURL        string    `db:"web_url"`
@@ -31,6 +40,192 @@ type Event struct {
 	VenueID       *uint64   `db:"venue_id"`
 	Venue         *venue    `db:"-"`
 	LocationURL   string    `db:"location_url"`
+	// Capacity of the online track of a hybrid event, 0 meaning no limit.
+	OnlineLimit int `db:"online_limit"`
+	// How many guests each RSVP can bring.
+	GuestLimit int `db:"guest_limit" validate:"min=0"`
+	// Only set when the event is an occurrence of a recurring Series.
+	SeriesID       *uint64    `db:"series_id"`
+	RecurrenceTime *time.Time `db:"recurrence_time"`
+	IsOverride     bool       `db:"is_override"`
+	// Revision number used by iCalendar feeds, bumped by Save whenever
+	// something attendees care about changes.
+	Sequence int `db:"sequence"`
+	// Drafts are only visible to hosts. A draft with a PublishTime is
+	// published automatically at that time.
+	Status       EventStatus `db:"status"`
+	PublishTime  *time.Time  `db:"publish_time"`
+	StatusReason string      `db:"status_reason"`
+	// Only hosts can comment on a locked event.
+	CommentsLocked bool `db:"comments_locked"`
+	// IANA timezone the event takes place in. StartTime and EndTime are UTC.
+	Timezone string `db:"timezone" validate:"required,timezone"`
+}
+
+/*
+ * Cancel cancels the event. The reason is shown on the event page.
+ */
+func (e *Event) Cancel(reason string) error {
+
+	e.Status = EventCancelled
+	e.StatusReason = reason
+
+	return e.Save()
+}
+
+/*
+ * cancel is Cancel within a transaction.
+ */
+func (e *Event) cancel(tx pgx.Tx, reason string) error {
+
+	e.Status = EventCancelled
+	e.StatusReason = reason
+
+	return e.save(tx)
+}
+
+/*
+ * Capacity returns how many attendees the event can take, 0 meaning there's no
+ * limit. Without an explicit attendee limit, the capacity of the venue is
+ * used. For hybrid events, that's the capacity of the in-person track.
+ */
+func (e *Event) Capacity() int {
+
+	if e.AttendeeLimit > 0 {
+		return e.AttendeeLimit
+	}
+
+	if e.Venue != nil && e.Venue.Capacity > 0 {
+		return int(e.Venue.Capacity)
+	}
+
+	return 0
+}
+
+/*
+ * Comments returns the comment threads of the event.
+ */
+func (e *Event) Comments() ([]*Comment, error) {
+	return GetCommentsByEvent(e.DB, e.ID)
+}
+
+/*
+ * Delete removes the event from the database along with its RSVPs.
+ */
+func (e *Event) Delete() error {
+	return e.delete(e.DB)
+}
+
+/*
+ * delete is Delete on the provided connection.
+ */
+func (e *Event) delete(db dbConn) error {
+
+	_, err := db.Exec(context.Background(), `DELETE FROM `+DB_TABLE_RSVP+` WHERE event_id=$1`, e.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(context.Background(), `DELETE FROM `+e.table()+` WHERE `+e.primaryKey()+`=$1`, e.ID)
+
+	return err
+}
+
+/*
+ * hasAttendees reports whether anyone RSVP'd to the event as an attendee, as
+ * opposed to its hosts and crew.
+ */
+func (e *Event) hasAttendees(tx pgx.Tx) (bool, error) {
+
+	var exists bool
+	q := `SELECT EXISTS (SELECT 1 FROM ` + DB_TABLE_RSVP + ` WHERE event_id=$1 AND role='attendee')`
+	err := tx.QueryRow(context.Background(), q, e.ID).Scan(&exists)
+
+	return exists, err
+}
+
+/*
+ * IsCancelled reports whether the event was cancelled.
+ */
+func (e *Event) IsCancelled() bool { return e.Status == EventCancelled }
+
+/*
+ * IsDraft reports whether the event wasn't published yet.
+ */
+func (e *Event) IsDraft() bool { return e.Status == EventDraft }
+
+/*
+ * IsPostponed reports whether the event was postponed to a date to be
+ * announced.
+ */
+func (e *Event) IsPostponed() bool { return e.Status == EventPostponed }
+
+/*
+ * IsPublic reports whether everyone can see the event, which is the case for
+ * every state but draft.
+ */
+func (e *Event) IsPublic() bool { return e.Status != EventDraft }
+
+/*
+ * LocalEnd returns the end time in the event's timezone.
+ */
+func (e *Event) LocalEnd() time.Time { return e.EndTime.In(e.Location()) }
+
+/*
+ * LocalStart returns the start time in the event's timezone.
+ */
+func (e *Event) LocalStart() time.Time { return e.StartTime.In(e.Location()) }
+
+/*
+ * Location returns the event's timezone as a time.Location.
+ */
+func (e *Event) Location() *time.Location { return loadLocation(e.Timezone) }
+
+/*
+ * Place returns a one-line, human readable description of where the event
+ * takes place.
+ */
+func (e *Event) Place() string {
+
+	if e.Venue != nil {
+		return e.Venue.Name + ", " + e.Venue.String()
+	}
+
+	if e.LocationURL != "" {
+		return e.LocationURL
+	}
+
+	return "to be determined"
+}
+
+/*
+ * LockComments stops members from commenting on the event, or lets them again.
+ */
+func (e *Event) LockComments(locked bool) error {
+
+	_, err := e.DB.Exec(context.Background(), `UPDATE `+e.table()+` SET comments_locked=@locked WHERE id=@id`, pgx.NamedArgs{
+		"locked": locked,
+		"id":     e.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.CommentsLocked = locked
+
+	return nil
+}
+
+/*
+ * Postpone postpones the event to a date to be announced. The reason is shown
+ * on the event page.
+ */
+func (e *Event) Postpone(reason string) error {
+
+	e.Status = EventPostponed
+	e.StatusReason = reason
+
+	return e.Save()
 }
 
 /*
@@ -38,6 +233,28 @@ type Event struct {
  */
 func (e *Event) primaryKey() string { return "id" }
 
+/*
+ * Publish makes the event public. A publish time in the future schedules it
+ * instead, the event stays a draft until then.
+ */
+func (e *Event) Publish(at *time.Time) error {
+
+	if at != nil && at.After(time.Now()) {
+
+		publishTime := at.UTC()
+		e.Status = EventDraft
+		e.PublishTime = &publishTime
+	} else {
+
+		e.Status = EventPublished
+		e.PublishTime = nil
+	}
+
+	e.StatusReason = ""
+
+	return e.Save()
+}
+
 /*
  * RSVPs returns a slice of RSVP for this event.
  */
@@ -47,10 +264,44 @@ func (e *Event) RSVPs() ([]*RSVP, error) {
 
 /*
  * save serializes the struct to the database. The update is done via primary
- * key.
+ * key. The struct is validated first so that edits follow the same rules as
+ * NewEvent. The sessions of the agenda move along with the start time.
  */
 func (e *Event) Save() error {
 
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = e.save(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * save is Save within a transaction.
+ */
+func (e *Event) save(tx pgx.Tx) error {
+
+	err := validate.Struct(e)
+	if err != nil {
+		return err
+	}
+
+	err = shiftAgenda(tx, e)
+	if err != nil {
+		return err
+	}
+
+	e.UpdatedTime = time.Now().UTC()
+
 	q := `UPDATE ` + e.table() + `
 	SET name=@name,
 		start_time=@startTime,
@@ -62,45 +313,100 @@ func (e *Event) Save() error {
 		attendee_limit=@attendeeLimit,
 		venue_id=@venueID,
 		location_url=@locationURL,
+		online_limit=@onlineLimit,
+		guest_limit=@guestLimit,
+		series_id=@seriesID,
+		recurrence_time=@recurrenceTime,
+		is_override=@isOverride,
+		sequence=sequence + (CASE WHEN (name, start_time, end_time, summary, description, venue_id, location_url, status)
+			IS DISTINCT FROM (@name, @startTime, @endTime, @summary, @description, @venueID, @locationURL, @status)
+			THEN 1 ELSE 0 END),
+		status=@status,
+		publish_time=@publishTime,
+		status_reason=@statusReason,
+		timezone=@timezone,
 		updated_time=@updatedTime
 	WHERE ` + e.primaryKey() + `=@id`
 
-	_, err := e.DB.Exec(context.Background(), q,
+	_, err = tx.Exec(context.Background(), q,
 		pgx.NamedArgs{
-			"name":          e.Name,
-			"startTime":     e.StartTime,
-			"endTime":       e.EndTime,
-			"summary":       e.Summary,
-			"description":   e.Description,
-			"webURL":        e.WebURL,
-			"announceURL":   e.AnnounceURL,
-			"attendeeLimit": e.AttendeeLimit,
-			"venueID":       e.VenueID,
-			"locationURL":   e.LocationURL,
-			"updatedTime":   e.UpdatedTime,
-			"id":            e.ID,
+			"name":           e.Name,
+			"startTime":      e.StartTime,
+			"endTime":        e.EndTime,
+			"summary":        e.Summary,
+			"description":    e.Description,
+			"webURL":         e.WebURL,
+			"announceURL":    e.AnnounceURL,
+			"attendeeLimit":  e.AttendeeLimit,
+			"venueID":        e.VenueID,
+			"locationURL":    e.LocationURL,
+			"onlineLimit":    e.OnlineLimit,
+			"guestLimit":     e.GuestLimit,
+			"seriesID":       e.SeriesID,
+			"recurrenceTime": e.RecurrenceTime,
+			"isOverride":     e.IsOverride,
+			"status":         e.Status,
+			"publishTime":    e.PublishTime,
+			"statusReason":   e.StatusReason,
+			"timezone":       e.Timezone,
+			"updatedTime":    e.UpdatedTime,
+			"id":             e.ID,
 		})
 
 	return err
 }
 
 /*
- * Display the event time based on context.
+ * Display the event time based on context, in the event's timezone. The zone
+ * abbreviation is shown once, or for both ends when a DST change happens
+ * during the event.
  */
 func (e *Event) SmartTime() string {
 
-	display := e.StartTime.Format("January 2, 2006 3:04p.m.") + " - "
-	if e.StartTime.Month() != e.EndTime.Month() {
-		display = display + " " + e.EndTime.Format("January")
+	start, end := e.LocalStart(), e.LocalEnd()
+
+	display := start.Format("January 2, 2006 3:04p.m.")
+	if start.Format("MST") != end.Format("MST") {
+		display = display + " " + start.Format("MST")
+	}
+	display = display + " - "
+
+	if start.Month() != end.Month() {
+		display = display + " " + end.Format("January")
 	}
-	if e.StartTime.Day() != e.EndTime.Day() {
-		display = display + " " + e.EndTime.Format("2,")
+	if start.YearDay() != end.YearDay() || start.Year() != end.Year() {
+		display = display + " " + end.Format("2,")
 	}
-	if e.StartTime.Year() != e.EndTime.Year() {
-		display = display + " " + e.EndTime.Format("2006")
+	if start.Year() != end.Year() {
+		display = display + " " + end.Format("2006")
 	}
 
-	return display + " " + e.EndTime.Format(" 3:04p.m.")
+	return display + " " + end.Format(" 3:04p.m. MST")
+}
+
+/*
+ * SetTimezone moves the event to another timezone. With keepWallClock, the
+ * local times stay the same, e.g. 7p.m. stays 7p.m. in the new timezone,
+ * otherwise the event keeps happening at the same instant. It isn't saved.
+ */
+func (e *Event) SetTimezone(timezone string, keepWallClock bool) error {
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+
+	if keepWallClock {
+
+		start, end := e.LocalStart(), e.LocalEnd()
+
+		e.StartTime = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc).UTC()
+		e.EndTime = time.Date(end.Year(), end.Month(), end.Day(), end.Hour(), end.Minute(), end.Second(), 0, loc).UTC()
+	}
+
+	e.Timezone = timezone
+
+	return nil
 }
 
 /*
@@ -127,14 +433,22 @@ func initEvent(db *pgxpool.Pool) *Event {
  * Create a new event in the system. Includes the base event itself, not
  * information such as the venue or groups.
  */
-func NewEvent(u *User, groupID uint64, name string, startTime, endTime time.Time, summary string) (*Event, error) {
+func NewEvent(u *User, groupID uint64, name string, startTime, endTime time.Time, timezone, summary string) (*Event, error) {
+	return newEvent(u.DB, u.ID, groupID, name, startTime, endTime, timezone, summary)
+}
+
+/*
+ * newEvent is NewEvent on the provided connection, with userID as the host.
+ */
+func newEvent(db dbConn, userID, groupID uint64, name string, startTime, endTime time.Time, timezone, summary string) (*Event, error) {
 
-	e := initEvent(u.DB)
+	e := new(Event)
 	e.Name = name
 	e.StartTime = startTime.UTC()
 	e.EndTime = endTime.UTC()
 	e.GroupID = groupID
 	e.Summary = summary
+	e.Timezone = timezone
 
 	// validate inputs
 	err := validate.Struct(e)
@@ -142,12 +456,13 @@ func NewEvent(u *User, groupID uint64, name string, startTime, endTime time.Time
 		return nil, err
 	}
 
-	q := `INSERT INTO ` + e.table() + ` (group_id, name, start_time, end_time, summary) VALUES (@groupID, @name, @startTime, @endTime, @summary) RETURNING *`
-	rows, _ := e.DB.Query(context.Background(), q, pgx.NamedArgs{
+	q := `INSERT INTO ` + e.table() + ` (group_id, name, start_time, end_time, timezone, summary) VALUES (@groupID, @name, @startTime, @endTime, @timezone, @summary) RETURNING *`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
 		"groupID":   groupID,
 		"name":      e.Name,
 		"startTime": e.StartTime,
 		"endTime":   e.EndTime,
+		"timezone":  e.Timezone,
 		"summary":   e.Summary,
 	})
 
@@ -158,7 +473,7 @@ func NewEvent(u *User, groupID uint64, name string, startTime, endTime time.Time
 
 	// When creating a new event, the user who created the event is automatically
 	// added as the host.
-	_, err = NewRSVP(e.ID, u, RSVPYes, RSVPHost)
+	_, err = newRSVP(db, e.ID, userID, RSVPYes, RSVPHost)
 
 	return e, err
 }
@@ -224,9 +539,7 @@ func GetEventsByQuery(db *pgxpool.Pool, q string, args any) ([]*Event, error) {
 
 	for _, e := range events {
 
-		e.DB = db
-
-		e.TheGroup, err = GetGroupByID(db, e.GroupID)
+		err = prepEvent(db, e)
 		if err != nil {
 			return nil, err
 		}
@@ -235,12 +548,41 @@ func GetEventsByQuery(db *pgxpool.Pool, q string, args any) ([]*Event, error) {
 	return events, nil
 }
 
+/*
+ * prepEvent loads the group and venue of an event read from the DB.
+ */
+func prepEvent(db *pgxpool.Pool, e *Event) error {
+
+	var err error
+
+	e.DB = db
+
+	e.TheGroup, err = GetGroupByID(db, e.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if e.VenueID != nil && *e.VenueID != uint64(0) {
+
+		e.Venue, err = GetVenueByID(db, *e.VenueID)
+		if err != nil {
+			return err
+		}
+		e.Venue.DB = db
+	}
+
+	return nil
+}
+
 /*
  * The generic helper to retrieve the model by a column.
  */
 func GetEvents(db *pgxpool.Pool, limit int) ([]*Event, error) {
 
-	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` LIMIT @limit`
+	q := `SELECT e.* FROM ` + DB_TABLE_EVENT + ` e
+		JOIN ` + DB_TABLE_GROUP + ` g ON g.id=e.group_id
+		WHERE e.status <> 'draft' AND g.archived_time IS NULL
+		LIMIT @limit`
 	args := pgx.NamedArgs{
 		"limit": limit,
 	}
@@ -249,7 +591,47 @@ func GetEvents(db *pgxpool.Pool, limit int) ([]*Event, error) {
 }
 
 /*
- * GetEventsByGroup returns a slice of Event.
+ * GetEventsByRSVP returns the events a user RSVP'd to with one of the
+ * provided intents, newest first.
+ */
+func GetEventsByRSVP(db *pgxpool.Pool, userID uint64, intents []RSVPStatus, limit int) ([]*Event, error) {
+
+	q := `SELECT e.* FROM ` + DB_TABLE_EVENT + ` e
+		JOIN ` + DB_TABLE_RSVP + ` r ON r.event_id=e.id
+		WHERE r.user_id=@userID AND r.intent::text = ANY(@intents) AND e.status <> 'draft'
+		ORDER BY e.start_time DESC
+		LIMIT @limit`
+
+	strIntents := make([]string, len(intents))
+	for i, intent := range intents {
+		strIntents[i] = string(intent)
+	}
+
+	args := pgx.NamedArgs{
+		"userID":  userID,
+		"intents": strIntents,
+		"limit":   limit,
+	}
+
+	return GetEventsByQuery(db, q, args)
+}
+
+/*
+ * GetDraftEventsByGroup returns the events of a group that weren't published
+ * yet, soonest first.
+ */
+func GetDraftEventsByGroup(db *pgxpool.Pool, groupID uint64) ([]*Event, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` WHERE group_id=@id AND status='draft' ORDER BY start_time`
+	args := pgx.NamedArgs{
+		"id": groupID,
+	}
+
+	return GetEventsByQuery(db, q, args)
+}
+
+/*
+ * GetEventsByGroup returns a slice of Event. Drafts aren't included.
  */
 func GetEventsByGroup(db *pgxpool.Pool, groupID uint64, pastEvents bool, limit uint8) ([]*Event, error) {
 
@@ -261,10 +643,44 @@ func GetEventsByGroup(db *pgxpool.Pool, groupID uint64, pastEvents bool, limit u
 		op = ">="
 	}
 
-	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` WHERE start_time ` + op + ` CURRENT_TIMESTAMP AND group_id=@id`
+	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` WHERE start_time ` + op + ` CURRENT_TIMESTAMP AND group_id=@id AND status <> 'draft'`
+
+	// Recurring series can create a lot of rows so the closest events are
+	// the ones that matter.
+	if pastEvents {
+		q = q + ` ORDER BY start_time DESC`
+	} else {
+		q = q + ` ORDER BY start_time ASC`
+	}
+	q = q + ` LIMIT @limit`
+
 	args := pgx.NamedArgs{
-		"id": groupID,
+		"id":    groupID,
+		"limit": limit,
 	}
 
 	return GetEventsByQuery(db, q, args)
 }
+
+/*
+ * PublishScheduledEvents publishes the drafts whose publish time has passed
+ * and returns how many there were. For a recurring series, the other draft
+ * occurrences are published along with it. Drafts of archived groups stay
+ * drafts.
+ */
+func PublishScheduledEvents(db *pgxpool.Pool, now time.Time) (int64, error) {
+
+	q := `UPDATE ` + DB_TABLE_EVENT + ` e
+		SET status='published', publish_time=NULL, sequence=e.sequence+1, updated_time=@now
+		FROM ` + DB_TABLE_GROUP + ` g
+		WHERE g.id=e.group_id AND g.archived_time IS NULL
+			AND e.status='draft' AND (e.publish_time <= @now OR e.series_id IN (
+				SELECT series_id FROM ` + DB_TABLE_EVENT + `
+				WHERE status='draft' AND publish_time <= @now AND series_id IS NOT NULL
+			))`
+	tag, err := db.Exec(context.Background(), q, pgx.NamedArgs{
+		"now": now.UTC(),
+	})
+
+	return tag.RowsAffected(), err
+}
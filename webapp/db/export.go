@@ -0,0 +1,153 @@
+package db
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Attendee exports, e.g. for building security. Email addresses are only
+ * included for members who chose to share them with hosts.
+ */
+
+// AttendeeStatuses are the statuses an attendee export can be filtered by.
+var AttendeeStatuses = []string{"going", "waitlist", "maybe", "no", "attended", "no-show"}
+
+// AttendeeCSVHeader is the header row of attendee CSV exports.
+var AttendeeCSVHeader = []string{
+	"Username", "First Name", "Last Name", "Email", "Intent", "Waitlisted",
+	"Actual", "Role", "Guests", "Guest Names", "RSVP Time",
+}
+
+/*
+ * AttendeeRecord is one line of an attendee export.
+ */
+type AttendeeRecord struct {
+	Username   string    `json:"username"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Email      string    `json:"email,omitempty"`
+	Intent     string    `json:"intent"`
+	Waitlisted bool      `json:"waitlisted"`
+	Actual     string    `json:"actual,omitempty"`
+	Role       string    `json:"role"`
+	Guests     int       `json:"guests"`
+	GuestNames []string  `json:"guest_names"`
+	RSVPTime   time.Time `json:"rsvp_time"`
+}
+
+/*
+ * CSV returns the record as a CSV row, in the order of AttendeeCSVHeader.
+ */
+func (a *AttendeeRecord) CSV() []string {
+
+	waitlisted := ""
+	if a.Waitlisted {
+		waitlisted = "yes"
+	}
+
+	return []string{
+		a.Username,
+		a.FirstName,
+		a.LastName,
+		a.Email,
+		a.Intent,
+		waitlisted,
+		a.Actual,
+		a.Role,
+		strconv.Itoa(a.Guests),
+		strings.Join(a.GuestNames, "; "),
+		a.RSVPTime.UTC().Format(time.RFC3339),
+	}
+}
+
+/*
+ * HasStatus reports whether the RSVP matches one of the export statuses.
+ */
+func (r *RSVP) HasStatus(statuses ...string) bool {
+
+	for _, status := range statuses {
+
+		switch status {
+		case "going":
+			if r.IsConfirmed() {
+				return true
+			}
+		case "waitlist":
+			if r.IsWaitlisted() {
+				return true
+			}
+		case "maybe", "no":
+			if string(r.Intent) == status {
+				return true
+			}
+		case "attended":
+			if r.Actual != nil && *r.Actual != RSVPNo {
+				return true
+			}
+		case "no-show":
+			if r.Actual != nil && *r.Actual == RSVPNo {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * GetAttendeeRecords returns the RSVPs of an event as export records, oldest
+ * first. With statuses, only RSVPs matching one of them are included.
+ */
+func GetAttendeeRecords(db *pgxpool.Pool, eventID uint64, statuses []string) ([]*AttendeeRecord, error) {
+
+	rsvps, err := GetRSVPsByEvent(db, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(rsvps, func(a, b *RSVP) int {
+		return a.CreatedTime.Compare(b.CreatedTime)
+	})
+
+	var records []*AttendeeRecord
+	for _, r := range rsvps {
+
+		if len(statuses) > 0 && !r.HasStatus(statuses...) {
+			continue
+		}
+
+		record := &AttendeeRecord{
+			Username:   r.TheUser.Username,
+			FirstName:  r.TheUser.FirstName,
+			LastName:   r.TheUser.LastName,
+			Intent:     string(r.Intent),
+			Waitlisted: r.IsWaitlisted(),
+			Actual:     r.ActualLabel(),
+			Role:       string(r.Role),
+			Guests:     r.Guests,
+			GuestNames: r.GuestNames,
+			RSVPTime:   r.CreatedTime,
+		}
+
+		if r.TheUser.ShareEmail {
+
+			email, err := GetPreferredEmailByUser(r.TheUser)
+			if err == nil && email != nil {
+				record.Email = email.Value
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_FEEDBACK = "feedback"
+
+/*
+ * Feedback is what an attendee thought of an event once it was over. It's
+ * anonymous, so who gave it is never shown, not even to hosts.
+ */
+type Feedback struct {
+	framework.BaseModel
+	EventID  uint64 `db:"event_id" validate:"required"`
+	UserID   uint64 `db:"user_id" validate:"required"`
+	Rating   int    `db:"rating" validate:"min=1,max=5"`
+	Comments string `db:"comments" validate:"max=5000"`
+}
+
+/*
+ * Ratings summarizes the feedback given for one or more events.
+ */
+type Ratings struct {
+	Count   int     `db:"count"`
+	Average float64 `db:"average"`
+	// How many gave each rating, from 1 to 5.
+	Ones   int `db:"ones"`
+	Twos   int `db:"twos"`
+	Threes int `db:"threes"`
+	Fours  int `db:"fours"`
+	Fives  int `db:"fives"`
+}
+
+/*
+ * EventRatings is the Ratings of one of the events of a group.
+ */
+type EventRatings struct {
+	Ratings
+	EventID   uint64    `db:"event_id"`
+	Name      string    `db:"name"`
+	StartTime time.Time `db:"start_time"`
+	// How the average moved since the previous event of the list, nil for
+	// the first one.
+	Change *float64 `db:"-"`
+}
+
+/*
+ * RatingShare is how many gave a rating, out of all the feedback.
+ */
+type RatingShare struct {
+	Rating  int
+	Count   int
+	Percent int
+}
+
+/*
+ * AverageString returns the average rating with one decimal, e.g. "4.3".
+ */
+func (r Ratings) AverageString() string {
+	return fmt.Sprintf("%.1f", r.Average)
+}
+
+/*
+ * Distribution returns how many gave each rating, from 5 down to 1, along
+ * with the percentage of the feedback it is.
+ */
+func (r Ratings) Distribution() []RatingShare {
+
+	var shares []RatingShare
+	for i, count := range []int{r.Fives, r.Fours, r.Threes, r.Twos, r.Ones} {
+
+		share := RatingShare{Rating: 5 - i, Count: count}
+		if r.Count > 0 {
+			share.Percent = count * 100 / r.Count
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares
+}
+
+/*
+ * ChangeString returns how the average moved since the previous event, e.g.
+ * "+0.4", or an empty string for the first event.
+ */
+func (r *EventRatings) ChangeString() string {
+
+	if r.Change == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%+.1f", *r.Change)
+}
+
+/*
+ * CanGiveFeedback reports whether the RSVP makes someone an attendee of the
+ * event, according to check-in if they were checked in or else because they
+ * had a spot. Hosts and crew don't rate their own events.
+ */
+func (r *RSVP) CanGiveFeedback() bool {
+
+	if r.IsStaff() {
+		return false
+	}
+
+	if r.Actual != nil {
+		return *r.Actual != RSVPNo
+	}
+
+	return r.IsConfirmed()
+}
+
+/*
+ * FeedbackOpen reports whether feedback can be given, which is from the end
+ * of the event.
+ */
+func (e *Event) FeedbackOpen(now time.Time) bool {
+	return e.IsPublic() && !e.IsCancelled() && !now.Before(e.EndTime)
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+// ratingsColumns aggregates feedback rows into the columns of Ratings.
+const ratingsColumns = `
+	count(f.rating) AS count,
+	COALESCE(avg(f.rating), 0)::float8 AS average,
+	count(*) FILTER (WHERE f.rating=1) AS ones,
+	count(*) FILTER (WHERE f.rating=2) AS twos,
+	count(*) FILTER (WHERE f.rating=3) AS threes,
+	count(*) FILTER (WHERE f.rating=4) AS fours,
+	count(*) FILTER (WHERE f.rating=5) AS fives`
+
+/*
+ * SaveFeedback stores a user's feedback for an event, replacing what they
+ * gave before.
+ */
+func SaveFeedback(db *pgxpool.Pool, eventID, userID uint64, rating int, comments string) (*Feedback, error) {
+
+	f := &Feedback{
+		EventID:  eventID,
+		UserID:   userID,
+		Rating:   rating,
+		Comments: strings.TrimSpace(comments),
+	}
+
+	err := validate.Struct(f)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO ` + DB_TABLE_FEEDBACK + ` (event_id, user_id, rating, comments)
+		VALUES (@eventID, @userID, @rating, @comments)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET rating=EXCLUDED.rating,
+			comments=EXCLUDED.comments,
+			updated_time=@updatedTime
+		RETURNING *`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID":     f.EventID,
+		"userID":      f.UserID,
+		"rating":      f.Rating,
+		"comments":    f.Comments,
+		"updatedTime": time.Now().UTC(),
+	})
+
+	f, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Feedback])
+	if err != nil {
+		return nil, err
+	}
+	f.DB = db
+
+	return f, nil
+}
+
+/*
+ * GetFeedback returns the feedback a user gave for an event.
+ */
+func GetFeedback(db *pgxpool.Pool, eventID, userID uint64) (*Feedback, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_FEEDBACK + ` WHERE event_id=@eventID AND user_id=@userID`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+		"userID":  userID,
+	})
+
+	f, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Feedback])
+	if err != nil {
+		return nil, err
+	}
+	f.DB = db
+
+	return f, nil
+}
+
+/*
+ * GetFeedbackComments returns the comments given for an event, newest first,
+ * with their rating. Who gave them isn't included.
+ */
+func GetFeedbackComments(db *pgxpool.Pool, eventID uint64) ([]*Feedback, error) {
+
+	// the user is left out on purpose
+	q := `SELECT id, event_id, 0::bigint AS user_id, rating, comments, created_time, updated_time
+		FROM ` + DB_TABLE_FEEDBACK + ` WHERE event_id=$1 AND comments <> '' ORDER BY updated_time DESC`
+	rows, _ := db.Query(context.Background(), q, eventID)
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Feedback])
+}
+
+/*
+ * GetRatingsByEvent returns the ratings of an event.
+ */
+func GetRatingsByEvent(db *pgxpool.Pool, eventID uint64) (Ratings, error) {
+
+	q := `SELECT ` + ratingsColumns + ` FROM ` + DB_TABLE_FEEDBACK + ` f WHERE f.event_id=$1`
+	rows, _ := db.Query(context.Background(), q, eventID)
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Ratings])
+}
+
+/*
+ * GetRatingsByGroup returns the ratings of every event of a group that got
+ * feedback, oldest first so that they show the trend.
+ */
+func GetRatingsByGroup(db *pgxpool.Pool, groupID uint64) ([]*EventRatings, error) {
+
+	q := `SELECT e.id AS event_id, e.name, e.start_time, ` + ratingsColumns + ` FROM ` + DB_TABLE_FEEDBACK + ` f
+		JOIN ` + DB_TABLE_EVENT + ` e ON e.id=f.event_id
+		WHERE e.group_id=@groupID
+		GROUP BY e.id
+		ORDER BY e.start_time`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"groupID": groupID,
+	})
+
+	ratings, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[EventRatings])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(ratings); i++ {
+		change := ratings[i].Average - ratings[i-1].Average
+		ratings[i].Change = &change
+	}
+
+	return ratings, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"time"
 
 	"github.com/eventhunt-org/webapp/framework"
 
@@ -28,6 +29,87 @@ type Group struct {
 	CityID      uint64 `db:"city_id" validate:"required"`
 	TheCity     *City  `db:"-"`
 	IsPrivate   bool   `db:"is_private"`
+	// Archived groups keep their history but can't schedule events.
+	ArchivedTime *time.Time `db:"archived_time"`
+}
+
+/*
+ * GroupDeletion describes what deleting a group would remove, and what stops
+ * it from being deleted.
+ */
+type GroupDeletion struct {
+	Events  int `db:"events"`
+	RSVPs   int `db:"rsvps"`
+	Members int `db:"members"`
+	// Upcoming events that attendees RSVP'd to. They need to be cancelled
+	// first so that attendees are told.
+	Upcoming int `db:"upcoming"`
+	// Paid orders that weren't refunded or cancelled. Their records can't
+	// be deleted.
+	Orders int `db:"orders"`
+}
+
+/*
+ * Archive hides the group and stops it from scheduling events. Its events
+ * and members are kept.
+ */
+func (g *Group) Archive() error {
+
+	now := time.Now().UTC()
+	g.ArchivedTime = &now
+
+	return g.Save()
+}
+
+/*
+ * Delete removes the group from the database along with its events, their
+ * RSVPs and everything else that belongs to them, its recurring series, and
+ * its memberships. Venues are shared between groups and are kept. Orders are
+ * the financial record of ticket sales, they're detached from their events
+ * instead of being deleted with them.
+ */
+func (g *Group) Delete() error {
+
+	ctx := context.Background()
+
+	tx, err := g.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := []string{
+		`UPDATE ` + DB_TABLE_ORDERS + ` SET event_id=NULL, ticket_type_id=NULL, updated_time=CURRENT_TIMESTAMP
+			WHERE event_id IN (SELECT id FROM ` + DB_TABLE_EVENT + ` WHERE group_id=$1)`,
+		`DELETE FROM ` + DB_TABLE_RSVP + ` WHERE event_id IN (SELECT id FROM ` + DB_TABLE_EVENT + ` WHERE group_id=$1)`,
+		`DELETE FROM ` + DB_TABLE_EVENT + ` WHERE group_id=$1`,
+		`DELETE FROM ` + DB_TABLE_SERIES + ` WHERE group_id=$1`,
+		`DELETE FROM ` + DB_TABLE_MEMBERSHIPS + ` WHERE group_id=$1`,
+		`DELETE FROM ` + g.table() + ` WHERE ` + g.primaryKey() + `=$1`,
+	}
+
+	for _, q := range queries {
+
+		_, err = tx.Exec(ctx, q, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * DraftEvents returns the events of the group that weren't published yet.
+ */
+func (g *Group) DraftEvents() []*Event {
+
+	events, err := GetDraftEventsByGroup(g.DB, g.ID)
+	if err != nil {
+		slog.Error("Failed to pull draft events.", "groupID", g.ID)
+	}
+
+	return events
 }
 
 /*
@@ -49,6 +131,11 @@ func (g *Group) HasCreate(id uint64) bool {
 	return false
 }
 
+/*
+ * IsArchived reports whether the group was archived.
+ */
+func (g *Group) IsArchived() bool { return g.ArchivedTime != nil }
+
 /*
  * IsMember returns true if the provided ID (User) is a member of this Group.
  */
@@ -65,6 +152,21 @@ func (g *Group) IsMember(id uint64) bool {
 	return false
 }
 
+/*
+ * IsOwner returns true if the provided ID (User) owns this Group. Only owners
+ * can change the group's settings, archive it, or delete it.
+ */
+func (g *Group) IsOwner(id uint64) bool {
+
+	for _, ms := range g.Memberships() {
+		if ms.TheUser.ID == id && ms.Role == MemberOwner {
+			return true
+		}
+	}
+
+	return false
+}
+
 /*
  * Members returns a slice of User who belong to the Group..
  */
@@ -83,7 +185,7 @@ func (g *Group) Memberships() []*Membership {
  */
 func (g *Group) PastEvents(count uint8) []*Event {
 
-	events, err := GetEventsByGroup(g.DB, g.ID, true, 10)
+	events, err := GetEventsByGroup(g.DB, g.ID, true, count)
 	if err != nil {
 		slog.Error("Failed to pull past events.", "groupID", g.ID)
 	}
@@ -98,24 +200,40 @@ func (g *Group) primaryKey() string { return "id" }
 
 /*
  * save serializes the struct to the database. The update is done via primary
- * key.
+ * key. The struct is validated first so that edits follow the same rules as
+ * NewGroup.
  */
 func (g *Group) Save() error {
 
+	err := validate.Struct(g)
+	if err != nil {
+		return err
+	}
+
+	g.UpdatedTime = time.Now().UTC()
+
 	q := `UPDATE ` + g.table() + ` 
 		SET user_id=@userID,
 			name=@name,
+			summary=@summary,
+			description=@description,
+			web_url=@webURL,
 			city_id=@cityID,
 			is_private=@isPrivate,
+			archived_time=@archivedTime,
 			updated_time=@updatedTime
 		WHERE ` + g.primaryKey() + ` = @id`
-	_, err := g.DB.Exec(context.Background(), q, pgx.NamedArgs{
-		"userID":      g.UserID,
-		"name":        g.Name,
-		"cityID":      g.CityID,
-		"isPrivate":   g.IsPrivate,
-		"updatedTime": g.UpdatedTime,
-		"id":          g.ID,
+	_, err = g.DB.Exec(context.Background(), q, pgx.NamedArgs{
+		"userID":       g.UserID,
+		"name":         g.Name,
+		"summary":      g.Summary,
+		"description":  g.Description,
+		"webURL":       g.WebURL,
+		"cityID":       g.CityID,
+		"isPrivate":    g.IsPrivate,
+		"archivedTime": g.ArchivedTime,
+		"updatedTime":  g.UpdatedTime,
+		"id":           g.ID,
 	})
 
 	return err
@@ -126,12 +244,22 @@ func (g *Group) Save() error {
  */
 func (g *Group) table() string { return "groups" }
 
+/*
+ * Unarchive brings an archived group back.
+ */
+func (g *Group) Unarchive() error {
+
+	g.ArchivedTime = nil
+
+	return g.Save()
+}
+
 /*
  * UpcomingEvents returns n number of future events.
  */
 func (g *Group) UpcomingEvents(count uint8) []*Event {
 
-	events, err := GetEventsByGroup(g.DB, g.ID, false, 10)
+	events, err := GetEventsByGroup(g.DB, g.ID, false, count)
 	if err != nil {
 		slog.Error("Failed to pull upcoming events.", "groupID", g.ID)
 	}
@@ -236,9 +364,7 @@ func GetGroupsByQuery(db *pgxpool.Pool, q string, args any) ([]*Group, error) {
 	// prep each group
 	for _, g := range groups {
 
-		g.DB = db
-
-		g.TheCity, err = GetCityByID(db, g.CityID)
+		err = prepGroup(db, g)
 		if err != nil {
 			return nil, err
 		}
@@ -247,6 +373,44 @@ func GetGroupsByQuery(db *pgxpool.Pool, q string, args any) ([]*Group, error) {
 	return groups, nil
 }
 
+/*
+ * prepGroup loads the city of a group read from the DB.
+ */
+func prepGroup(db *pgxpool.Pool, g *Group) error {
+
+	var err error
+
+	g.DB = db
+
+	g.TheCity, err = GetCityByID(db, g.CityID)
+
+	return err
+}
+
+/*
+ * GetGroupDeletion returns what deleting the group would remove.
+ */
+func GetGroupDeletion(db *pgxpool.Pool, groupID uint64) (*GroupDeletion, error) {
+
+	q := `SELECT
+		(SELECT count(*) FROM ` + DB_TABLE_EVENT + ` WHERE group_id=@groupID) AS events,
+		(SELECT count(*) FROM ` + DB_TABLE_RSVP + ` r
+			JOIN ` + DB_TABLE_EVENT + ` e ON e.id=r.event_id
+			WHERE e.group_id=@groupID AND r.role='attendee') AS rsvps,
+		(SELECT count(*) FROM ` + DB_TABLE_MEMBERSHIPS + ` WHERE group_id=@groupID) AS members,
+		(SELECT count(*) FROM ` + DB_TABLE_EVENT + ` e
+			WHERE e.group_id=@groupID AND e.status IN ('published', 'postponed') AND e.end_time >= CURRENT_TIMESTAMP
+				AND EXISTS (SELECT 1 FROM ` + DB_TABLE_RSVP + ` r WHERE r.event_id=e.id AND r.role='attendee')) AS upcoming,
+		(SELECT count(*) FROM ` + DB_TABLE_ORDERS + ` o
+			JOIN ` + DB_TABLE_EVENT + ` e ON e.id=o.event_id
+			WHERE e.group_id=@groupID AND o.amount > 0 AND o.status IN ('pending', 'confirmed')) AS orders`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"groupID": groupID,
+	})
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[GroupDeletion])
+}
+
 /*
  * GetGroupsByID returns a group with the provided group ID.
  */
@@ -262,25 +426,31 @@ func GetGroupByID(db *pgxpool.Pool, id uint64) (*Group, error) {
 		return nil, err
 	}
 
+	if len(groups) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
 	return groups[0], nil
 }
 
 /*
  * GetGroupsByLimit returns a slice of Group with a max count of 'limit'.
+ * Archived groups are left out.
  */
 func GetGroupsByLimit(db *pgxpool.Pool, limit uint64) ([]*Group, error) {
 
-	q := `SELECT * FROM ` + DB_TABLE_GROUP + ` LIMIT ` + strconv.FormatUint(limit, 10)
+	q := `SELECT * FROM ` + DB_TABLE_GROUP + ` WHERE archived_time IS NULL LIMIT ` + strconv.FormatUint(limit, 10)
 
 	return GetGroupsByQuery(db, q, nil)
 }
 
 /*
  * GetGroupsByUser returns a slice of group containing groups owned by the user.
+ * Archived groups come last.
  */
 func GetGroupsByUser(u *User) ([]*Group, error) {
 
-	q := `SELECT * FROM ` + DB_TABLE_GROUP + ` WHERE user_id = @userID LIMIT 25`
+	q := `SELECT * FROM ` + DB_TABLE_GROUP + ` WHERE user_id = @userID ORDER BY archived_time IS NOT NULL, id LIMIT 25`
 	args := pgx.NamedArgs{
 		"userID": u.ID,
 	}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Hybrid events take place at a venue and online at the same time. Each of
+ * the two tracks has its own capacity and waitlist order, and RSVPs pick one
+ * with the in-person or online intent. Other events have a single track and
+ * take plain yes RSVPs.
+ */
+
+/*
+ * TrackCounts is how many spots are taken on each track of an event, guests
+ * included.
+ */
+type TrackCounts struct {
+	InPerson int `db:"in_person"`
+	Online   int `db:"online"`
+}
+
+/*
+ * Of returns the count of a track as returned by Event.Track.
+ */
+func (c TrackCounts) Of(track RSVPStatus) int {
+
+	switch track {
+	case RSVPInPerson:
+		return c.InPerson
+	case RSVPOnline:
+		return c.Online
+	}
+
+	return c.Total()
+}
+
+/*
+ * Total returns how many spots are taken on all tracks.
+ */
+func (c TrackCounts) Total() int {
+	return c.InPerson + c.Online
+}
+
+/*
+ * CanSeeOnlineLink reports whether the user can see the online link of the
+ * event. For hybrid events it's kept to confirmed online attendees and to
+ * whoever can change the place, so that in-person attendees and the waitlist
+ * don't get around the online capacity.
+ */
+func (e *Event) CanSeeOnlineLink(userID uint64) bool {
+
+	if !e.IsHybrid() {
+		return true
+	}
+
+	if userID == 0 {
+		return false
+	}
+
+	if e.Can(userID, EventPermVenue) {
+		return true
+	}
+
+	r, err := GetRSVP(e.DB, e.ID, userID)
+	if err != nil {
+		return false
+	}
+
+	return r.IsStaff() || (r.IsConfirmed() && r.Intent == RSVPOnline)
+}
+
+/*
+ * Intent returns the intent an RSVP is saved with. Hybrid events need a
+ * track, a plain yes being in person. Other events take a plain yes.
+ */
+func (e *Event) Intent(intent RSVPStatus) RSVPStatus {
+
+	if e.IsHybrid() && intent == RSVPYes {
+		return RSVPInPerson
+	}
+
+	if !e.IsHybrid() && intent.IsAttending() {
+		return RSVPYes
+	}
+
+	return intent
+}
+
+/*
+ * IsHybrid reports whether the event has both a venue and an online link.
+ */
+func (e *Event) IsHybrid() bool {
+	return e.VenueID != nil && e.LocationURL != ""
+}
+
+/*
+ * Track returns the track an attending intent takes a spot on. Events that
+ * aren't hybrid only have the yes track.
+ */
+func (e *Event) Track(intent RSVPStatus) RSVPStatus {
+
+	if !e.IsHybrid() {
+		return RSVPYes
+	}
+
+	if intent == RSVPOnline {
+		return RSVPOnline
+	}
+
+	return RSVPInPerson
+}
+
+/*
+ * TrackCapacity returns how many attendees a track can take, 0 meaning
+ * there's no limit.
+ */
+func (e *Event) TrackCapacity(track RSVPStatus) int {
+
+	if track == RSVPOnline {
+		return e.OnlineLimit
+	}
+
+	return e.Capacity()
+}
+
+/*
+ * TrackCounts returns how many attendees have a spot on each track.
+ */
+func (e *Event) TrackCounts() TrackCounts {
+
+	counts, err := GetTrackCountsByEvent(e.DB, e.ID)
+	if err != nil {
+		return TrackCounts{}
+	}
+
+	return counts
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+const trackCountsQuery = `SELECT
+	COALESCE(sum(1 + guests) FILTER (WHERE intent IN ('yes', 'in-person')), 0) AS in_person,
+	COALESCE(sum(1 + guests) FILTER (WHERE intent='online'), 0) AS online
+	FROM ` + DB_TABLE_RSVP + `
+	WHERE event_id=$1 AND role='attendee' AND waitlist_position IS NULL`
+
+/*
+ * GetTrackCountsByEvent returns how many attendees have a spot on each track
+ * of an event.
+ */
+func GetTrackCountsByEvent(db *pgxpool.Pool, eventID uint64) (TrackCounts, error) {
+
+	rows, _ := db.Query(context.Background(), trackCountsQuery, eventID)
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[TrackCounts])
+}
+
+/*
+ * countTracks is GetTrackCountsByEvent within a transaction.
+ */
+func countTracks(tx pgx.Tx, eventID uint64) (TrackCounts, error) {
+
+	rows, _ := tx.Query(context.Background(), trackCountsQuery, eventID)
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[TrackCounts])
+}
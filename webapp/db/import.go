@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_EVENT_IMPORTS = "event_imports"
+
+/*
+ * Events imported from iCalendar files are recorded by the UID they had in the
+ * file so that a group importing the same file twice doesn't get the events
+ * twice. An occurrence of a recurring event has its own UID, made of the UID
+ * of the event and its recurrence.
+ */
+
+/*
+ * GetImportedUIDs returns the UIDs of the events imported into a group.
+ */
+func GetImportedUIDs(db *pgxpool.Pool, groupID uint64) (map[string]bool, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT uid FROM `+DB_TABLE_EVENT_IMPORTS+` WHERE group_id=$1`, groupID)
+
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		imported[uid] = true
+	}
+
+	return imported, nil
+}
+
+/*
+ * NewImportedEvent creates an event imported into a group with the provided
+ * UID, hosted by u. The event is created from the fields of imported that an
+ * import sets. The event and the record of its import are written in a single
+ * transaction so that an import that failed can be run again without
+ * duplicating events.
+ */
+func NewImportedEvent(u *User, uid string, imported *Event) (*Event, error) {
+
+	ctx := context.Background()
+
+	tx, err := u.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	e, err := newEvent(tx, u.ID, imported.GroupID, imported.Name, imported.StartTime, imported.EndTime, imported.Timezone, imported.Summary)
+	if err != nil {
+		return nil, err
+	}
+	e.DB = u.DB
+
+	e.Description = imported.Description
+	e.WebURL = imported.WebURL
+	e.VenueID = imported.VenueID
+	e.LocationURL = imported.LocationURL
+	e.Status = imported.Status
+
+	err = e.save(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO ` + DB_TABLE_EVENT_IMPORTS + ` (group_id, uid, event_id) VALUES ($1, $2, $3)
+		ON CONFLICT (group_id, uid) DO NOTHING`
+	_, err = tx.Exec(ctx, q, e.GroupID, uid, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, tx.Commit(ctx)
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NearbyRadius is the default radius of a "near me" search, in kilometers.
+const NearbyRadius = 25
+
+// nearbyCities finds the cities within the radius of a point, using the
+// cities_location_idx index, along with their distance in meters. Events and
+// groups are then matched by city.
+const nearbyCities = `WITH nearby AS (
+		SELECT id, ST_Distance(location, ST_SetSRID(ST_MakePoint(@lng, @lat), 4326)::geography) AS distance
+		FROM ` + DB_TABLE_CITY + `
+		WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint(@lng, @lat), 4326)::geography, @meters)
+	)`
+
+/*
+ * Point is a location on Earth in degrees.
+ */
+type Point struct {
+	Lat float64 `db:"lat"`
+	Lng float64 `db:"lng"`
+}
+
+/*
+ * NearbyEvent is an event found by a "near me" search with its distance in
+ * kilometers.
+ */
+type NearbyEvent struct {
+	*Event
+	Distance float64
+}
+
+/*
+ * NearbyGroup is a group found by a "near me" search with its distance in
+ * kilometers.
+ */
+type NearbyGroup struct {
+	*Group
+	Distance float64
+}
+
+/*
+ * nearbyEvent and nearbyGroup are rows of a "near me" search, with the
+ * distance in meters.
+ */
+type nearbyEvent struct {
+	Event
+	Distance float64 `db:"distance"`
+}
+
+type nearbyGroup struct {
+	Group
+	Distance float64 `db:"distance"`
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * GetCityPoint returns the location of a city.
+ */
+func GetCityPoint(db *pgxpool.Pool, cityID uint64) (*Point, error) {
+
+	q := `SELECT ST_Y(location::geometry) AS lat, ST_X(location::geometry) AS lng
+		FROM ` + DB_TABLE_CITY + ` WHERE id=@id`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"id": cityID,
+	})
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Point])
+}
+
+/*
+ * GetEventsNear returns the upcoming events within radius kilometers of a
+ * point, the closest first. An event is located at the city of its venue or,
+ * without a venue, the city of its group. Private groups are left out unless
+ * they belong to userID, and archived groups are left out.
+ */
+func GetEventsNear(db *pgxpool.Pool, p Point, radius float64, userID uint64, limit int) ([]*NearbyEvent, error) {
+
+	q := nearbyCities + `
+		SELECT e.*, nearby.distance
+		FROM ` + DB_TABLE_EVENT + ` e
+		JOIN groups g ON g.id=e.group_id
+		LEFT JOIN venues v ON v.id=e.venue_id
+		JOIN nearby ON nearby.id=COALESCE(v.city_id, g.city_id)
+		WHERE e.status <> 'draft'
+			AND e.end_time >= CURRENT_TIMESTAMP
+			AND (NOT g.is_private OR g.user_id=@userID)
+			AND g.archived_time IS NULL
+		ORDER BY nearby.distance ASC, e.start_time ASC
+		LIMIT @limit`
+
+	rows, _ := db.Query(context.Background(), q, nearbyArgs(p, radius, userID, limit))
+	found, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByNameLax[nearbyEvent])
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*NearbyEvent, 0, len(found))
+	for _, n := range found {
+
+		err = prepEvent(db, &n.Event)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &NearbyEvent{&n.Event, n.Distance / 1000})
+	}
+
+	return events, nil
+}
+
+/*
+ * GetGroupsNear returns the groups within radius kilometers of a point, the
+ * closest first. Private groups are left out unless they belong to userID, and
+ * archived groups are left out.
+ */
+func GetGroupsNear(db *pgxpool.Pool, p Point, radius float64, userID uint64, limit int) ([]*NearbyGroup, error) {
+
+	q := nearbyCities + `
+		SELECT g.*, nearby.distance
+		FROM ` + DB_TABLE_GROUP + ` g
+		JOIN nearby ON nearby.id=g.city_id
+		WHERE (NOT g.is_private OR g.user_id=@userID) AND g.archived_time IS NULL
+		ORDER BY nearby.distance ASC, g.name ASC
+		LIMIT @limit`
+
+	rows, _ := db.Query(context.Background(), q, nearbyArgs(p, radius, userID, limit))
+	found, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByNameLax[nearbyGroup])
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*NearbyGroup, 0, len(found))
+	for _, n := range found {
+
+		err = prepGroup(db, &n.Group)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, &NearbyGroup{&n.Group, n.Distance / 1000})
+	}
+
+	return groups, nil
+}
+
+/*
+ * nearbyArgs returns the arguments of a query built on nearbyCities.
+ */
+func nearbyArgs(p Point, radius float64, userID uint64, limit int) pgx.NamedArgs {
+
+	return pgx.NamedArgs{
+		"lat":    p.Lat,
+		"lng":    p.Lng,
+		"meters": radius * 1000,
+		"userID": userID,
+		"limit":  limit,
+	}
+}
@@ -0,0 +1,415 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_QUESTIONS = "questions"
+const DB_TABLE_ANSWERS = "answers"
+
+type QuestionKind string
+
+const (
+	QuestionText     QuestionKind = "text"
+	QuestionSingle   QuestionKind = "single"
+	QuestionMulti    QuestionKind = "multi"
+	QuestionCheckbox QuestionKind = "checkbox"
+)
+
+// maxAnswerLength is the longest a text answer can be.
+const maxAnswerLength = 1000
+
+/*
+ * Question is asked to people RSVPing to an event, such as their dietary needs
+ * or T-shirt size. Single and multi choice questions pick from Choices. A
+ * required checkbox has to be checked, e.g. to agree to a code of conduct.
+ */
+type Question struct {
+	framework.BaseModel
+	EventID    uint64       `db:"event_id" validate:"required"`
+	Label      string       `db:"label" validate:"required,max=200"`
+	Kind       QuestionKind `db:"kind" validate:"oneof=text single multi checkbox"`
+	Choices    []string     `db:"choices"`
+	IsRequired bool         `db:"is_required"`
+}
+
+/*
+ * Answer is what someone answered to a question when they RSVP'd. Value has
+ * one item at most, except for multi choice questions.
+ */
+type Answer struct {
+	QuestionID  uint64    `db:"question_id"`
+	UserID      uint64    `db:"user_id"`
+	Value       []string  `db:"value"`
+	TheQuestion *Question `db:"-"`
+}
+
+/*
+ * AnswerRow is an RSVP along with its answers, one per question of the event
+ * in order.
+ */
+type AnswerRow struct {
+	RSVP    *RSVP
+	Answers []*Answer
+}
+
+/*
+ * Has reports whether the choice was picked.
+ */
+func (a *Answer) Has(choice string) bool {
+	return slices.Contains(a.Value, choice)
+}
+
+/*
+ * String returns the answer as one line, choices separated by commas.
+ */
+func (a *Answer) String() string {
+	return strings.Join(a.Value, ", ")
+}
+
+/*
+ * Answer checks the values submitted for the question and returns them as an
+ * Answer. Empty values are dropped. The Answer is returned along with the
+ * error so that the form can be shown again as it was filled.
+ */
+func (q *Question) Answer(values []string) (*Answer, error) {
+
+	a := &Answer{QuestionID: q.ID, TheQuestion: q}
+
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(a.Value, v) {
+			a.Value = append(a.Value, v)
+		}
+	}
+
+	if len(a.Value) == 0 {
+
+		if q.IsRequired {
+			return a, errors.New("\"" + q.Label + "\" is required.")
+		}
+
+		return a, nil
+	}
+
+	switch q.Kind {
+	case QuestionText:
+		if len(a.Value) > 1 || len(a.Value[0]) > maxAnswerLength {
+			return a, errors.New("The answer to \"" + q.Label + "\" is too long.")
+		}
+	case QuestionCheckbox:
+		a.Value = []string{"yes"}
+	case QuestionSingle, QuestionMulti:
+		if q.Kind == QuestionSingle && len(a.Value) > 1 {
+			return a, errors.New("Pick one answer to \"" + q.Label + "\".")
+		}
+		for _, v := range a.Value {
+			if !slices.Contains(q.Choices, v) {
+				return a, errors.New("\"" + v + "\" isn't a choice for \"" + q.Label + "\".")
+			}
+		}
+	}
+
+	return a, nil
+}
+
+/*
+ * Delete removes the question along with its answers.
+ */
+func (q *Question) Delete() error {
+
+	_, err := q.DB.Exec(context.Background(), `DELETE FROM `+q.table()+` WHERE id=$1`, q.ID)
+
+	return err
+}
+
+/*
+ * HasChoices reports whether the question is answered by picking choices.
+ */
+func (q *Question) HasChoices() bool {
+	return q.Kind == QuestionSingle || q.Kind == QuestionMulti
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (q *Question) primaryKey() string { return "id" }
+
+/*
+ * table returns the table name used in the database.
+ */
+func (q *Question) table() string { return DB_TABLE_QUESTIONS }
+
+/*
+ * Questions returns the registration questions of the event.
+ */
+func (e *Event) Questions() []*Question {
+
+	questions, err := GetQuestionsByEvent(e.DB, e.ID)
+	if err != nil {
+		return nil
+	}
+
+	return questions
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * Internal init function.
+ */
+func initQuestion(db *pgxpool.Pool) *Question {
+
+	q := new(Question)
+	q.DB = db
+
+	return q
+}
+
+/*
+ * NewQuestion adds a registration question to an event. Choices are only kept
+ * for single and multi choice questions, which need at least one.
+ */
+func NewQuestion(db *pgxpool.Pool, eventID uint64, label string, kind QuestionKind, choices []string, required bool) (*Question, error) {
+
+	q := initQuestion(db)
+	q.EventID = eventID
+	q.Label = strings.TrimSpace(label)
+	q.Kind = kind
+	q.IsRequired = required
+	q.Choices = []string{}
+
+	if q.HasChoices() {
+
+		for _, c := range choices {
+			if c = strings.TrimSpace(c); c != "" && !slices.Contains(q.Choices, c) {
+				q.Choices = append(q.Choices, c)
+			}
+		}
+
+		if len(q.Choices) == 0 {
+			return nil, errors.New("Choice questions need at least one choice.")
+		}
+	}
+
+	err := validate.Struct(q)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := `INSERT INTO ` + q.table() + ` (event_id, label, kind, choices, is_required)
+		VALUES (@eventID, @label, @kind, @choices, @isRequired) RETURNING *`
+	rows, _ := db.Query(context.Background(), sql, pgx.NamedArgs{
+		"eventID":    q.EventID,
+		"label":      q.Label,
+		"kind":       q.Kind,
+		"choices":    q.Choices,
+		"isRequired": q.IsRequired,
+	})
+
+	q, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Question])
+	if err != nil {
+		return nil, err
+	}
+	q.DB = db
+
+	return q, nil
+}
+
+/*
+ * GetQuestionByID returns the question with the provided ID.
+ */
+func GetQuestionByID(db *pgxpool.Pool, id uint64) (*Question, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_QUESTIONS+` WHERE id=$1`, id)
+
+	q, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Question])
+	if err != nil {
+		return nil, err
+	}
+	q.DB = db
+
+	return q, nil
+}
+
+/*
+ * GetQuestionsByEvent returns the questions of an event in the order they
+ * were added.
+ */
+func GetQuestionsByEvent(db *pgxpool.Pool, eventID uint64) ([]*Question, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_QUESTIONS+` WHERE event_id=$1 ORDER BY id`, eventID)
+
+	questions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Question])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range questions {
+		q.DB = db
+	}
+
+	return questions, nil
+}
+
+/*
+ * CopyQuestions adds the questions of an event to another one, without the
+ * answers.
+ */
+func CopyQuestions(db *pgxpool.Pool, fromEventID, toEventID uint64) error {
+
+	q := `INSERT INTO ` + DB_TABLE_QUESTIONS + ` (event_id, label, kind, choices, is_required)
+		SELECT @toEventID, label, kind, choices, is_required FROM ` + DB_TABLE_QUESTIONS + `
+		WHERE event_id=@fromEventID ORDER BY id`
+	_, err := db.Exec(context.Background(), q, pgx.NamedArgs{
+		"fromEventID": fromEventID,
+		"toEventID":   toEventID,
+	})
+
+	return err
+}
+
+/*
+ * GetAnswers returns a user's answers to the questions provided, in the same
+ * order. Questions they didn't answer get an empty Answer.
+ */
+func GetAnswers(db *pgxpool.Pool, eventID, userID uint64, questions []*Question) ([]*Answer, error) {
+
+	q := `SELECT question_id, user_id, value FROM ` + DB_TABLE_ANSWERS + ` WHERE event_id=@eventID AND user_id=@userID`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+		"userID":  userID,
+	})
+
+	given, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Answer])
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]*Answer, len(questions))
+	for i, question := range questions {
+
+		answers[i] = &Answer{QuestionID: question.ID, UserID: userID, TheQuestion: question}
+
+		for _, a := range given {
+			if a.QuestionID == question.ID {
+				answers[i].Value = a.Value
+			}
+		}
+	}
+
+	return answers, nil
+}
+
+/*
+ * SaveAnswers stores a user's answers against their RSVP to the event, which
+ * has to exist. Empty answers remove what was answered before.
+ */
+func SaveAnswers(db *pgxpool.Pool, eventID, userID uint64, answers []*Answer) error {
+
+	ctx := context.Background()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, a := range answers {
+
+		args := pgx.NamedArgs{
+			"eventID":    eventID,
+			"userID":     userID,
+			"questionID": a.QuestionID,
+			"value":      a.Value,
+		}
+
+		if len(a.Value) == 0 {
+
+			_, err = tx.Exec(ctx, `DELETE FROM `+DB_TABLE_ANSWERS+` WHERE question_id=@questionID AND user_id=@userID`, args)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		q := `INSERT INTO ` + DB_TABLE_ANSWERS + ` (event_id, user_id, question_id, value)
+			VALUES (@eventID, @userID, @questionID, @value)
+			ON CONFLICT (question_id, user_id) DO UPDATE
+			SET value=EXCLUDED.value,
+				updated_time=@updatedTime`
+		args["updatedTime"] = time.Now().UTC()
+
+		_, err = tx.Exec(ctx, q, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * GetAnswerRowsByEvent returns the answers of everyone attending or on the
+ * waitlist of an event, to the questions provided.
+ */
+func GetAnswerRowsByEvent(db *pgxpool.Pool, eventID uint64, questions []*Question) ([]*AnswerRow, error) {
+
+	rsvps, err := GetRSVPsByEvent(db, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT question_id, user_id, value FROM ` + DB_TABLE_ANSWERS + ` WHERE event_id=@eventID`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+	})
+
+	given, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Answer])
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := map[uint64]map[uint64][]string{}
+	for _, a := range given {
+
+		if byUser[a.UserID] == nil {
+			byUser[a.UserID] = map[uint64][]string{}
+		}
+
+		byUser[a.UserID][a.QuestionID] = a.Value
+	}
+
+	var answerRows []*AnswerRow
+	for _, r := range rsvps {
+
+		if !r.Intent.IsAttending() || r.IsStaff() {
+			continue
+		}
+
+		row := &AnswerRow{RSVP: r}
+		for _, question := range questions {
+			row.Answers = append(row.Answers, &Answer{
+				QuestionID:  question.ID,
+				UserID:      r.UserID,
+				Value:       byUser[r.UserID][question.ID],
+				TheQuestion: question,
+			})
+		}
+
+		answerRows = append(answerRows, row)
+	}
+
+	return answerRows, nil
+}
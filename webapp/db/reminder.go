@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Reminder is an RSVP that was claimed for sending a reminder email.
+ */
+type Reminder struct {
+	EventID uint64 `db:"event_id"`
+	UserID  uint64 `db:"user_id"`
+	// reminded_time before the claim, used to release it if sending fails.
+	Previous *time.Time `db:"previous"`
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * ClaimReminders finds the RSVPs that are due a reminder offset before their
+ * event starts and marks them as reminded at now, in a single statement.
+ *
+ * An RSVP is due when the reminder time (start - offset) has passed, the event
+ * hasn't started yet, and the last reminder was sent before the reminder time.
+ * Because the claim sets reminded_time, running this again, after a restart
+ * or from another replica, won't return the same RSVP for that offset. Rows
+ * being claimed by another replica are skipped. The database keeps
+ * microseconds so now is truncated to that.
+ */
+func ClaimReminders(db *pgxpool.Pool, now time.Time, offset time.Duration) ([]*Reminder, error) {
+
+	q := `WITH due AS (
+			SELECT r.event_id, r.user_id, r.reminded_time AS previous
+			FROM ` + DB_TABLE_RSVP + ` r
+			JOIN ` + DB_TABLE_EVENT + ` e ON e.id=r.event_id
+			WHERE r.intent IN ('yes', 'in-person', 'online', 'maybe')
+				AND r.waitlist_position IS NULL
+				AND e.status='published'
+				AND e.start_time > @now
+				AND e.start_time - @offset::interval <= @now
+				AND (r.reminded_time IS NULL OR r.reminded_time < e.start_time - @offset::interval)
+			FOR UPDATE OF r SKIP LOCKED
+		)
+		UPDATE ` + DB_TABLE_RSVP + ` r
+		SET reminded_time=@now
+		FROM due
+		WHERE r.event_id=due.event_id AND r.user_id=due.user_id
+		RETURNING due.event_id, due.user_id, due.previous`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"now":    now.UTC().Truncate(time.Microsecond),
+		"offset": offset,
+	})
+
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Reminder])
+}
+
+/*
+ * ReleaseReminder undoes a claim, so that the reminder is tried again on the
+ * next run. It only applies if nothing else changed reminded_time since.
+ */
+func ReleaseReminder(db *pgxpool.Pool, r *Reminder, claimedAt time.Time) error {
+
+	q := `UPDATE ` + DB_TABLE_RSVP + ` SET reminded_time=@previous
+		WHERE event_id=@eventID AND user_id=@userID AND reminded_time=@claimedAt`
+	_, err := db.Exec(context.Background(), q, pgx.NamedArgs{
+		"previous":  r.Previous,
+		"eventID":   r.EventID,
+		"userID":    r.UserID,
+		"claimedAt": claimedAt.UTC().Truncate(time.Microsecond),
+	})
+
+	return err
+}
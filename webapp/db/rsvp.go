@@ -43,6 +43,54 @@ type RSVP struct {
 	Actual       *RSVPStatus `db:"actual"`
 	Role         RSVPRole    `db:"role"`
 	RemindedTime *time.Time  `db:"reminded_time"`
+	// Set when the user said yes but the event was full. Lower goes first.
+	WaitlistPosition *int `db:"waitlist_position"`
+	// The user showed up without an RSVP and was added during check-in.
+	WalkIn bool `db:"walk_in"`
+	// People the user brings along, up to the event's guest limit. Naming
+	// them is optional.
+	Guests     int      `db:"guests" validate:"min=0"`
+	GuestNames []string `db:"guest_names"`
+}
+
+/*
+ * ActualLabel describes what happened according to check-in. Empty when the
+ * user wasn't checked in yet.
+ */
+func (r *RSVP) ActualLabel() string {
+
+	if r.Actual == nil {
+		return ""
+	}
+
+	if *r.Actual == RSVPNo {
+		return "no-show"
+	}
+
+	return string(*r.Actual)
+}
+
+/*
+ * IsAttending reports whether the status means the user will be there, in
+ * any form.
+ */
+func (s RSVPStatus) IsAttending() bool {
+	return s == RSVPYes || s == RSVPInPerson || s == RSVPOnline
+}
+
+/*
+ * IsConfirmed reports whether the user is attending and has a spot, as opposed
+ * to being on the waitlist.
+ */
+func (r *RSVP) IsConfirmed() bool {
+	return r.Intent.IsAttending() && r.WaitlistPosition == nil
+}
+
+/*
+ * IsWaitlisted reports whether the user is waiting for a spot.
+ */
+func (r *RSVP) IsWaitlisted() bool {
+	return r.WaitlistPosition != nil
 }
 
 /*
@@ -50,27 +98,45 @@ type RSVP struct {
  */
 func (r *RSVP) primaryKey() string { return "" }
 
+/*
+ * Spots returns how many spots the RSVP takes, the user and their guests.
+ */
+func (r *RSVP) Spots() int {
+	return 1 + r.Guests
+}
+
 /*
  * save serializes the struct to the database. The update is done via primary
  * key.
  */
 func (r *RSVP) Save() error {
 
+	// the column can't be NULL
+	if r.GuestNames == nil {
+		r.GuestNames = []string{}
+	}
+
 	q := `UPDATE ` + r.table() + ` 
 		SET intent=@intent,
 			actual=@actual,
 			role=@role,
 			reminded_time=@remindedTime,
+			waitlist_position=@waitlistPosition,
+			guests=@guests,
+			guest_names=@guestNames,
 			updated_time=@updatedTime
 		WHERE event_id=@eventID AND user_id=@userID`
 	_, err := r.DB.Exec(context.Background(), q, pgx.NamedArgs{
-		"intent":       r.Intent,
-		"actual":       r.Actual,
-		"role":         r.Role,
-		"remindedTime": r.RemindedTime,
-		"updatedTime":  r.UpdatedTime,
-		"eventID":      r.EventID,
-		"userID":       r.UserID,
+		"intent":           r.Intent,
+		"actual":           r.Actual,
+		"role":             r.Role,
+		"remindedTime":     r.RemindedTime,
+		"waitlistPosition": r.WaitlistPosition,
+		"guests":           r.Guests,
+		"guestNames":       r.GuestNames,
+		"updatedTime":      r.UpdatedTime,
+		"eventID":          r.EventID,
+		"userID":           r.UserID,
 	})
 
 	return err
@@ -101,10 +167,17 @@ func initRSVP(db *pgxpool.Pool) *RSVP {
  * the database.
  */
 func NewRSVP(eventID uint64, u *User, intent RSVPStatus, role RSVPRole) (*RSVP, error) {
+	return newRSVP(u.DB, eventID, u.ID, intent, role)
+}
+
+/*
+ * newRSVP is NewRSVP on the provided connection.
+ */
+func newRSVP(db dbConn, eventID, userID uint64, intent RSVPStatus, role RSVPRole) (*RSVP, error) {
 
-	r := initRSVP(u.DB)
+	r := new(RSVP)
 	r.EventID = eventID
-	r.UserID = u.ID
+	r.UserID = userID
 	r.Intent = intent
 	r.Role = role
 
@@ -116,7 +189,7 @@ func NewRSVP(eventID uint64, u *User, intent RSVPStatus, role RSVPRole) (*RSVP,
 	q := `INSERT INTO ` + r.table() + ` 
 		(event_id, user_id, intent, role) 
 		VALUES (@eventID, @userID, @intent, @role) RETURNING *`
-	rows, _ := u.DB.Query(context.Background(), q, pgx.NamedArgs{
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
 		"eventID": r.EventID,
 		"userID":  r.UserID,
 		"intent":  r.Intent,
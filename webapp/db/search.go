@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SearchPerPage is the number of events on a page of search results.
+const SearchPerPage = 20
+
+// searchVector is the full-text document of an event. It must stay the same as
+// the expression of the events_search_idx index for the index to be used.
+const searchVector = `(setweight(to_tsvector('english', e.name), 'A') || setweight(to_tsvector('english', e.summary), 'B') || setweight(to_tsvector('english', e.description), 'C'))`
+
+// groupSearchVector is the same for the name of the group, which matches the
+// groups_search_idx index.
+const groupSearchVector = `to_tsvector('english', g.name)`
+
+// searchFrom joins everything the filters need. An event takes place in the
+// city of its venue or, for online events, the city of its group.
+const searchFrom = ` FROM ` + DB_TABLE_EVENT + ` e
+	JOIN groups g ON g.id=e.group_id
+	JOIN ` + DB_TABLE_CITY + ` gc ON gc.id=g.city_id
+	LEFT JOIN venues v ON v.id=e.venue_id
+	LEFT JOIN ` + DB_TABLE_CITY + ` vc ON vc.id=v.city_id`
+
+// searchFormats gives each event a row per format it has, so that hybrid
+// events are counted under both.
+const searchFormats = ` JOIN LATERAL (VALUES ('in-person', e.venue_id IS NOT NULL), ('online', e.location_url <> '')) f(format, matches) ON f.matches`
+
+/*
+ * EventSearch describes a search for events. Zero values mean no filter.
+ */
+type EventSearch struct {
+	Query   string
+	From    *time.Time
+	To      *time.Time
+	Country string // ISO alpha-2 code
+	Region  string // admin1 code within Country
+	CityID  uint64
+	// "online" or "in-person"
+	Format string
+	// Who is searching. Used for MineOnly and to show events of their own
+	// private groups.
+	UserID   uint64
+	MineOnly bool
+	Page     int
+}
+
+/*
+ * Facet is one possible value of a filter, with how many results it would
+ * have.
+ */
+type Facet struct {
+	Value string `db:"value"`
+	Label string `db:"label"`
+	Count int    `db:"count"`
+}
+
+/*
+ * EventSearchResult is a page of events matching an EventSearch, best matches
+ * first, along with the facets of the search.
+ */
+type EventSearchResult struct {
+	Events  []*Event
+	Total   int
+	Page    int
+	Pages   int
+	Formats []Facet
+	// Countries are always available, regions once a country is picked and
+	// cities once a region is picked.
+	Countries []Facet
+	Regions   []Facet
+	Cities    []Facet
+}
+
+/*
+ * where builds the WHERE clause of the search along with its arguments. The
+ * filter named by skip is left out, which is how facets count the results
+ * they would have.
+ */
+func (s *EventSearch) where(skip string) (string, pgx.NamedArgs) {
+
+	args := pgx.NamedArgs{
+		"userID": s.UserID,
+	}
+
+	// drafts and private groups aren't for everyone, archived groups are
+	// gone for everyone
+	conditions := []string{
+		`e.status <> 'draft'`,
+		`(NOT g.is_private OR g.user_id=@userID)`,
+		`g.archived_time IS NULL`,
+	}
+
+	if s.Query != "" {
+		conditions = append(conditions, `(`+searchVector+` @@ websearch_to_tsquery('english', @query) OR `+groupSearchVector+` @@ websearch_to_tsquery('english', @query))`)
+		args["query"] = s.Query
+	}
+
+	if s.From != nil {
+		conditions = append(conditions, `e.end_time >= @from`)
+		args["from"] = s.From.UTC()
+	} else {
+		conditions = append(conditions, `e.end_time >= CURRENT_TIMESTAMP`)
+	}
+
+	if s.To != nil {
+		conditions = append(conditions, `e.start_time < @to`)
+		args["to"] = s.To.UTC()
+	}
+
+	if s.Country != "" && skip != "country" {
+		conditions = append(conditions, `COALESCE(vc.iso_alpha2, gc.iso_alpha2)=@country`)
+		args["country"] = s.Country
+	}
+
+	if s.Region != "" && skip != "country" && skip != "region" {
+		conditions = append(conditions, `COALESCE(vc.admin1, gc.admin1)=@region`)
+		args["region"] = s.Region
+	}
+
+	if s.CityID != 0 && skip != "country" && skip != "region" && skip != "city" {
+		conditions = append(conditions, `COALESCE(vc.id, gc.id)=@cityID`)
+		args["cityID"] = s.CityID
+	}
+
+	// hybrid events have both a venue and an online URL, they're in both
+	if skip != "format" {
+		switch s.Format {
+		case "online":
+			conditions = append(conditions, `e.location_url <> ''`)
+		case "in-person":
+			conditions = append(conditions, `e.venue_id IS NOT NULL`)
+		}
+	}
+
+	if s.MineOnly {
+		conditions = append(conditions, `(g.user_id=@userID OR g.id IN (SELECT group_id FROM `+DB_TABLE_MEMBERSHIPS+` WHERE user_id=@userID))`)
+	}
+
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * SearchEvents runs a search. With a query, results are ranked by relevance,
+ * otherwise the soonest events come first.
+ */
+func SearchEvents(db *pgxpool.Pool, s *EventSearch) (*EventSearchResult, error) {
+
+	ctx := context.Background()
+	result := &EventSearchResult{
+		Page: s.Page,
+	}
+	if result.Page < 1 {
+		result.Page = 1
+	}
+
+	where, args := s.where("")
+
+	err := db.QueryRow(ctx, `SELECT count(*)`+searchFrom+where, args).Scan(&result.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Pages = (result.Total + SearchPerPage - 1) / SearchPerPage
+
+	order := ` ORDER BY e.start_time ASC, e.id ASC`
+	if s.Query != "" {
+		order = ` ORDER BY ts_rank_cd(` + searchVector + ` || setweight(` + groupSearchVector + `, 'B'), websearch_to_tsquery('english', @query)) DESC, e.start_time ASC, e.id ASC`
+	}
+
+	args["limit"] = SearchPerPage
+	args["offset"] = (result.Page - 1) * SearchPerPage
+
+	result.Events, err = GetEventsByQuery(db, `SELECT e.*`+searchFrom+where+order+` LIMIT @limit OFFSET @offset`, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Formats, err = searchFacet(db, s, "format", `f.format`, searchFormats, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result.Countries, err = searchFacet(db, s, "country", `COALESCE(vc.iso_alpha2, gc.iso_alpha2)`,
+		` JOIN countries co ON co.iso_alpha2=COALESCE(vc.iso_alpha2, gc.iso_alpha2)`, `co.name`)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Country != "" {
+		result.Regions, err = searchFacet(db, s, "region", `COALESCE(vc.admin1, gc.admin1)`,
+			` JOIN spr ON spr.iso_alpha2=COALESCE(vc.iso_alpha2, gc.iso_alpha2) AND spr.admin1=COALESCE(vc.admin1, gc.admin1)`, `spr.name`)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Region != "" {
+		result.Cities, err = searchFacet(db, s, "city", `COALESCE(vc.id, gc.id)::text`, "", `COALESCE(vc.name, gc.name)`)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+/*
+ * searchFacet counts the results of the search for each value of a filter,
+ * ignoring the current value of that filter. Empty values are left out.
+ */
+func searchFacet(db *pgxpool.Pool, s *EventSearch, name, value, join, label string) ([]Facet, error) {
+
+	if label == "" {
+		label = value
+	}
+
+	where, args := s.where(name)
+
+	q := `SELECT ` + value + ` AS value, ` + label + ` AS label, count(*) AS count` + searchFrom + join + where + `
+		GROUP BY 1, 2
+		HAVING ` + value + ` <> ''
+		ORDER BY count DESC, label ASC
+		LIMIT 50`
+	rows, _ := db.Query(context.Background(), q, args)
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[Facet])
+}
@@ -0,0 +1,725 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/teambition/rrule-go"
+)
+
+const DB_TABLE_SERIES = "event_series"
+
+// SeriesHorizon is how far into the future occurrences of a Series are
+// created as events.
+const SeriesHorizon = 90 * 24 * time.Hour
+
+// SeriesMaxOccurrences caps the number of future events a single Series can
+// have at once.
+const SeriesMaxOccurrences = 60
+
+/*
+ * Series represents a recurring event. It works as a template, each
+ * occurrence is created as a regular Event so that RSVPs, venues, etc. keep
+ * working as they do for one-off events.
+ *
+ * The recurrence is an RFC 5545 RRULE, expanded in the timezone of the
+ * series.
+ */
+type Series struct {
+	framework.BaseModel
+	GroupID       uint64      `db:"group_id" validate:"required"`
+	UserID        uint64      `db:"user_id" validate:"required"`
+	Name          string      `db:"name" validate:"required,min=3,max=80"`
+	Summary       string      `db:"summary"`
+	Description   string      `db:"description"`
+	WebURL        string      `db:"web_url"`
+	AttendeeLimit int         `db:"attendee_limit"`
+	VenueID       *uint64     `db:"venue_id"`
+	LocationURL   string      `db:"location_url"`
+	OnlineLimit   int         `db:"online_limit"`
+	GuestLimit    int         `db:"guest_limit"`
+	RRule         string      `db:"rrule" validate:"required"`
+	StartTime     time.Time   `db:"start_time" validate:"required"`
+	EndTime       time.Time   `db:"end_time" validate:"required,gtfield=StartTime"`
+	Timezone      string      `db:"timezone" validate:"required,timezone"`
+	ExDates       []time.Time `db:"exdates"`
+}
+
+/*
+ * AddExDate excludes an occurrence from the series. Its event is removed, or
+ * cancelled if people RSVP'd to it, the next time the series is synced.
+ */
+func (s *Series) AddExDate(recurrence time.Time) error {
+
+	s.ExDates = append(s.ExDates, recurrence.UTC())
+
+	return s.Save()
+}
+
+/*
+ * apply copies the series template onto one of its events, with the event
+ * starting at the provided time.
+ */
+func (s *Series) apply(e *Event, start time.Time) {
+
+	e.Name = s.Name
+	e.Summary = s.Summary
+	e.Description = s.Description
+	e.WebURL = s.WebURL
+	e.AttendeeLimit = s.AttendeeLimit
+	e.VenueID = s.VenueID
+	e.LocationURL = s.LocationURL
+	e.OnlineLimit = s.OnlineLimit
+	e.GuestLimit = s.GuestLimit
+	e.Timezone = s.Timezone
+	e.StartTime = start.UTC()
+	e.EndTime = WallClockEnd(start, s.StartTime, s.EndTime, loadLocation(s.Timezone))
+}
+
+/*
+ * Duration returns how long each occurrence lasts.
+ */
+func (s *Series) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+/*
+ * Events returns the events of the series that have been created so far.
+ */
+func (s *Series) Events() ([]*Event, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` WHERE series_id=@seriesID ORDER BY start_time`
+
+	return GetEventsByQuery(s.DB, q, pgx.NamedArgs{
+		"seriesID": s.ID,
+	})
+}
+
+/*
+ * isPublished reports whether any event of the series left the draft state.
+ */
+func (s *Series) isPublished(tx pgx.Tx) (bool, error) {
+
+	var published bool
+
+	q := `SELECT EXISTS(SELECT 1 FROM ` + DB_TABLE_EVENT + ` WHERE series_id=$1 AND status <> 'draft')`
+	err := tx.QueryRow(context.Background(), q, s.ID).Scan(&published)
+
+	return published, err
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (s *Series) primaryKey() string { return "id" }
+
+/*
+ * Publish publishes every draft occurrence of the series.
+ */
+func (s *Series) Publish() error {
+
+	q := `UPDATE ` + DB_TABLE_EVENT + `
+		SET status='published', publish_time=NULL, sequence=sequence+1, updated_time=CURRENT_TIMESTAMP
+		WHERE series_id=$1 AND status='draft'`
+	_, err := s.DB.Exec(context.Background(), q, s.ID)
+
+	return err
+}
+
+/*
+ * Reschedule changes the time of day and the duration of every occurrence,
+ * based on the provided start and end times. The dates of the occurrences are
+ * controlled by the RRULE and stay the same.
+ */
+func (s *Series) Reschedule(start, end time.Time) error {
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return err
+	}
+
+	first := s.StartTime.In(loc)
+	clock := start.In(loc)
+
+	s.StartTime = time.Date(first.Year(), first.Month(), first.Day(), clock.Hour(), clock.Minute(), 0, 0, loc).UTC()
+	s.EndTime = WallClockEnd(s.StartTime, start, end, loc)
+
+	return nil
+}
+
+/*
+ * RRuleSet builds the recurrence set of the series. It's expanded in the
+ * series' timezone so that occurrences keep their wall clock time across DST
+ * transitions.
+ */
+func (s *Series) RRuleSet() (*rrule.Set, error) {
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	opt, err := ParseRRule(s.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	opt.Dtstart = s.StartTime.In(loc)
+
+	r, err := rrule.NewRRule(*opt)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &rrule.Set{}
+	set.RRule(r)
+	set.SetExDates(s.ExDates)
+
+	return set, nil
+}
+
+/*
+ * Save serializes the struct to the database. The update is done via primary
+ * key.
+ */
+func (s *Series) Save() error {
+	return s.save(s.DB)
+}
+
+/*
+ * save is Save on the provided connection.
+ */
+func (s *Series) save(db dbConn) error {
+
+	err := validate.Struct(s)
+	if err != nil {
+		return err
+	}
+
+	s.UpdatedTime = time.Now().UTC()
+
+	// the column can't be NULL
+	if s.ExDates == nil {
+		s.ExDates = []time.Time{}
+	}
+
+	q := `UPDATE ` + s.table() + `
+	SET name=@name,
+		summary=@summary,
+		description=@description,
+		web_url=@webURL,
+		attendee_limit=@attendeeLimit,
+		venue_id=@venueID,
+		location_url=@locationURL,
+		online_limit=@onlineLimit,
+		guest_limit=@guestLimit,
+		rrule=@rrule,
+		start_time=@startTime,
+		end_time=@endTime,
+		timezone=@timezone,
+		exdates=@exdates,
+		updated_time=@updatedTime
+	WHERE ` + s.primaryKey() + `=@id`
+
+	_, err = db.Exec(context.Background(), q, pgx.NamedArgs{
+		"name":          s.Name,
+		"summary":       s.Summary,
+		"description":   s.Description,
+		"webURL":        s.WebURL,
+		"attendeeLimit": s.AttendeeLimit,
+		"venueID":       s.VenueID,
+		"locationURL":   s.LocationURL,
+		"onlineLimit":   s.OnlineLimit,
+		"guestLimit":    s.GuestLimit,
+		"rrule":         s.RRule,
+		"startTime":     s.StartTime,
+		"endTime":       s.EndTime,
+		"timezone":      s.Timezone,
+		"exdates":       s.ExDates,
+		"updatedTime":   s.UpdatedTime,
+		"id":            s.ID,
+	})
+
+	return err
+}
+
+/*
+ * Split ends the series right before the provided occurrence and returns a
+ * new Series, starting with that occurrence, that carries on with the same
+ * rule. Events from that occurrence on are moved to the new Series. This is
+ * what "this and following events" edits are built on.
+ */
+func (s *Series) Split(e *Event) (*Series, error) {
+
+	if e.SeriesID == nil || *e.SeriesID != s.ID || e.RecurrenceTime == nil {
+		return nil, errors.New("The event isn't an occurrence of this series.")
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	recurrence := e.RecurrenceTime.UTC()
+
+	oldOpt, err := ParseRRule(s.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	newOpt := *oldOpt
+
+	// COUNT covers the whole series so the new one only gets what's left.
+	if oldOpt.Count > 0 {
+
+		oldOpt.Dtstart = s.StartTime.In(loc)
+		r, err := rrule.NewRRule(*oldOpt)
+		if err != nil {
+			return nil, err
+		}
+
+		newOpt.Count = oldOpt.Count - len(r.Between(s.StartTime.Add(-time.Second), recurrence, false))
+		oldOpt.Count = 0
+	}
+	oldOpt.Until = recurrence.Add(-time.Second)
+
+	ns := initSeries(s.DB)
+	*ns = *s
+	ns.RRule = newOpt.RRuleString()
+	ns.ExDates = nil
+	s.RRule = oldOpt.RRuleString()
+
+	var exDates []time.Time
+	for _, exDate := range s.ExDates {
+		if exDate.Before(recurrence) {
+			exDates = append(exDates, exDate)
+		} else {
+			ns.ExDates = append(ns.ExDates, exDate)
+		}
+	}
+	s.ExDates = exDates
+
+	local := recurrence.In(loc)
+	clock := s.StartTime.In(loc)
+	ns.StartTime = time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc).UTC()
+	ns.EndTime = ns.StartTime.Add(s.Duration())
+
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockSeries(tx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err = insertSeries(tx, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.save(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `UPDATE ` + DB_TABLE_EVENT + ` SET series_id=@newID WHERE series_id=@oldID AND recurrence_time >= @recurrence`
+	_, err = tx.Exec(ctx, q, pgx.NamedArgs{
+		"newID":      ns.ID,
+		"oldID":      s.ID,
+		"recurrence": recurrence,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ns, tx.Commit(ctx)
+}
+
+/*
+ * Sync makes the events of the series match its rule from now until
+ * SeriesHorizon. Missing occurrences are created, occurrences that are not
+ * overrides are updated from the series, and events that no longer match
+ * the rule (for example because of a new EXDATE) are removed. Those that
+ * attendees RSVP'd to are cancelled instead and returned, so that the
+ * attendees can be told. Past events are never touched.
+ *
+ * The sync is done in a single transaction with the series locked, so that
+ * concurrent syncs, e.g. the job running on several servers, don't create the
+ * same occurrences twice.
+ */
+func (s *Series) Sync() ([]*Event, error) {
+
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockSeries(tx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := s.sync(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the attendees of cancelled events are notified, which needs the group
+	for _, e := range cancelled {
+
+		err = prepEvent(s.DB, e)
+		if err != nil {
+			return cancelled, err
+		}
+	}
+
+	return cancelled, nil
+}
+
+/*
+ * sync does the work of Sync within the transaction.
+ */
+func (s *Series) sync(tx pgx.Tx) ([]*Event, error) {
+
+	ctx := context.Background()
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := s.RRuleSet()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	occurrences := set.Between(now, now.Add(SeriesHorizon), true)
+	if len(occurrences) > SeriesMaxOccurrences {
+		occurrences = occurrences[:SeriesMaxOccurrences]
+	}
+
+	q := `SELECT * FROM ` + DB_TABLE_EVENT + ` WHERE series_id=@seriesID AND recurrence_time >= @now`
+	rows, _ := tx.Query(ctx, q, pgx.NamedArgs{
+		"seriesID": s.ID,
+		"now":      now,
+	})
+	events, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByNameLax[Event])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		e.DB = s.DB
+	}
+
+	// Existing events are matched by their local date so that changing the
+	// time of day of a series updates its events instead of replacing them,
+	// which would lose their RSVPs.
+	existing := make(map[string]*Event)
+	for _, e := range events {
+		existing[e.RecurrenceTime.In(loc).Format(time.DateOnly)] = e
+	}
+
+	// New occurrences are public once the series is, that is once one of its
+	// events was published.
+	published, err := s.isPublished(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, occurrence := range occurrences {
+
+		key := occurrence.In(loc).Format(time.DateOnly)
+		recurrence := occurrence.UTC()
+
+		e, ok := existing[key]
+		if ok {
+			delete(existing, key)
+		} else {
+
+			// the series creator is the host
+			e, err = newEvent(tx, s.UserID, s.GroupID, s.Name, recurrence, WallClockEnd(recurrence, s.StartTime, s.EndTime, loc), s.Timezone, s.Summary)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to create occurrence %s. Err: %s", key, err)
+			}
+			e.DB = s.DB
+			e.SeriesID = &s.ID
+
+			if published {
+				e.Status = EventPublished
+			}
+		}
+
+		e.RecurrenceTime = &recurrence
+		if !e.IsOverride {
+			s.apply(e, recurrence)
+		}
+
+		err = e.save(tx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to save occurrence %s. Err: %w", key, err)
+		}
+	}
+
+	var cancelled []*Event
+
+	for _, e := range existing {
+
+		// Deleting an occurrence would take its RSVPs and orders with it.
+		hasAttendees, err := e.hasAttendees(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		if !hasAttendees {
+
+			err = e.delete(tx)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to remove occurrence %d. Err: %s", e.ID, err)
+			}
+			continue
+		}
+
+		if e.IsCancelled() {
+			continue
+		}
+
+		err = e.cancel(tx, "This date was removed from the recurring series.")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to cancel occurrence %d. Err: %s", e.ID, err)
+		}
+
+		cancelled = append(cancelled, e)
+	}
+
+	return cancelled, nil
+}
+
+/*
+ * table returns the table name used in the database.
+ */
+func (s *Series) table() string { return DB_TABLE_SERIES }
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * Internal init function.
+ */
+func initSeries(db *pgxpool.Pool) *Series {
+
+	s := new(Series)
+	s.DB = db
+
+	return s
+}
+
+/*
+ * insertSeries validates the provided Series and inserts it as a new row.
+ */
+func insertSeries(db dbConn, s *Series) (*Series, error) {
+
+	err := validate.Struct(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// the column can't be NULL
+	if s.ExDates == nil {
+		s.ExDates = []time.Time{}
+	}
+
+	q := `INSERT INTO ` + s.table() + `
+		(group_id, user_id, name, summary, description, web_url, attendee_limit, venue_id, location_url, online_limit, guest_limit, rrule, start_time, end_time, timezone, exdates)
+		VALUES (@groupID, @userID, @name, @summary, @description, @webURL, @attendeeLimit, @venueID, @locationURL, @onlineLimit, @guestLimit, @rrule, @startTime, @endTime, @timezone, @exdates) RETURNING *`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"groupID":       s.GroupID,
+		"userID":        s.UserID,
+		"name":          s.Name,
+		"summary":       s.Summary,
+		"description":   s.Description,
+		"webURL":        s.WebURL,
+		"attendeeLimit": s.AttendeeLimit,
+		"venueID":       s.VenueID,
+		"locationURL":   s.LocationURL,
+		"onlineLimit":   s.OnlineLimit,
+		"guestLimit":    s.GuestLimit,
+		"rrule":         s.RRule,
+		"startTime":     s.StartTime,
+		"endTime":       s.EndTime,
+		"timezone":      s.Timezone,
+		"exdates":       s.ExDates,
+	})
+
+	pool := s.DB
+	s, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Series])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create series. Err: %s", err)
+	}
+	s.DB = pool
+
+	return s, nil
+}
+
+/*
+ * lockSeries locks the series row until the end of the transaction.
+ */
+func lockSeries(tx pgx.Tx, seriesID uint64) error {
+
+	_, err := tx.Exec(context.Background(), `SELECT id FROM `+DB_TABLE_SERIES+` WHERE id=$1 FOR UPDATE`, seriesID)
+
+	return err
+}
+
+/*
+ * NewSeries creates a recurring series for a group and then creates its
+ * upcoming occurrences as events. The first occurrence starts at startTime.
+ */
+func NewSeries(u *User, groupID uint64, name string, startTime, endTime time.Time, timezone, rule string, exDates []time.Time, summary string) (*Series, error) {
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	opt, err := ParseRRule(rule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := initSeries(u.DB)
+	s.GroupID = groupID
+	s.UserID = u.ID
+	s.Name = name
+	s.Summary = summary
+	s.RRule = opt.RRuleString()
+	s.StartTime = startTime.UTC()
+	s.EndTime = endTime.UTC()
+	s.Timezone = timezone
+	s.ExDates = exDates
+
+	s, err = insertSeries(s.DB, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new series has no RSVPs, nothing gets cancelled
+	_, err = s.Sync()
+
+	return s, err
+}
+
+/*
+ * ParseRRule parses an RFC 5545 RRULE value, with or without the "RRULE:"
+ * prefix. Rules that repeat more often than daily aren't supported as an
+ * event happening several times a day is not something EventHunt deals with.
+ */
+func ParseRRule(rule string, loc *time.Location) (*rrule.ROption, error) {
+
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+
+	opt, err := rrule.StrToROptionInLocation(rule, loc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid RRULE. Err: %s", err)
+	}
+
+	switch opt.Freq {
+	case rrule.YEARLY, rrule.MONTHLY, rrule.WEEKLY, rrule.DAILY:
+	default:
+		return nil, errors.New("Only daily, weekly, monthly, and yearly rules are supported.")
+	}
+
+	if len(opt.Byhour) != 0 || len(opt.Byminute) != 0 || len(opt.Bysecond) != 0 {
+		return nil, errors.New("BYHOUR, BYMINUTE, and BYSECOND are not supported.")
+	}
+
+	return opt, nil
+}
+
+/*
+ * GetSeriesByQuery returns a slice of Series from the DB based on the provided
+ * query.
+ */
+func GetSeriesByQuery(db *pgxpool.Pool, q string, args any) ([]*Series, error) {
+
+	var rows pgx.Rows
+
+	if args == nil {
+		rows, _ = db.Query(context.Background(), q)
+	} else {
+		rows, _ = db.Query(context.Background(), q, args)
+	}
+	series, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Series])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range series {
+		s.DB = db
+	}
+
+	return series, nil
+}
+
+/*
+ * GetSeriesByID returns the Series with the provided ID.
+ */
+func GetSeriesByID(db *pgxpool.Pool, id uint64) (*Series, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_SERIES + ` WHERE id=@id`
+	series, err := GetSeriesByQuery(db, q, pgx.NamedArgs{
+		"id": id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(series) == 0 {
+		return nil, errors.New("Series " + strconv.FormatUint(id, 10) + " not found.")
+	}
+
+	return series[0], nil
+}
+
+/*
+ * GetSeriesAll returns every Series.
+ */
+func GetSeriesAll(db *pgxpool.Pool) ([]*Series, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_SERIES
+
+	return GetSeriesByQuery(db, q, nil)
+}
+
+/*
+ * GetSeriesActive returns every Series of groups that aren't archived.
+ */
+func GetSeriesActive(db *pgxpool.Pool) ([]*Series, error) {
+
+	q := `SELECT s.* FROM ` + DB_TABLE_SERIES + ` s
+		JOIN ` + DB_TABLE_GROUP + ` g ON g.id=s.group_id
+		WHERE g.archived_time IS NULL`
+
+	return GetSeriesByQuery(db, q, nil)
+}
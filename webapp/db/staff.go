@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Event staff. Group hosts can give someone the host or crew role on a single
+ * event, through their RSVP, without making them a cohost of the group. Staff
+ * don't take a spot and get the permissions of their role on that event only.
+ */
+
+type EventPermission string
+
+const (
+	EventPermCheckIn   EventPermission = "check-in"
+	EventPermVenue     EventPermission = "venue"
+	EventPermMessage   EventPermission = "message"
+	EventPermAnswers   EventPermission = "answers"
+	EventPermAttendees EventPermission = "attendees"
+)
+
+// rolePermissions is what each staff role can do on its event. Group hosts
+// can do everything.
+var rolePermissions = map[RSVPRole][]EventPermission{
+	RSVPHost: {EventPermCheckIn, EventPermVenue, EventPermMessage, EventPermAnswers, EventPermAttendees},
+	RSVPCrew: {EventPermCheckIn},
+}
+
+/*
+ * Can reports whether the user has a permission on the event, either as a
+ * host of the group or as event staff.
+ */
+func (e *Event) Can(userID uint64, perm EventPermission) bool {
+
+	if e.TheGroup.HasCreate(userID) {
+		return true
+	}
+
+	r, err := GetRSVP(e.DB, e.ID, userID)
+	if err != nil {
+		return false
+	}
+
+	return slices.Contains(rolePermissions[r.Role], perm)
+}
+
+/*
+ * IsStaff reports whether the RSVP is for a host or crew member of the event.
+ */
+func (r *RSVP) IsStaff() bool {
+	return r.Role == RSVPHost || r.Role == RSVPCrew
+}
+
+/*
+ * Staff returns the hosts and crew of the event.
+ */
+func (e *Event) Staff() []*RSVP {
+
+	staff, err := GetStaffByEvent(e.DB, e.ID)
+	if err != nil {
+		return nil
+	}
+
+	return staff
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * SetStaff gives a user the host or crew role on an event. Their RSVP becomes
+ * a yes that doesn't count toward the capacity, so a spot they held goes to
+ * the waitlist. The promoted RSVPs are returned.
+ */
+func SetStaff(e *Event, userID uint64, role RSVPRole) ([]*RSVP, error) {
+
+	if role != RSVPHost && role != RSVPCrew {
+		return nil, errors.New("Staff role must be host or crew.")
+	}
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `INSERT INTO ` + DB_TABLE_RSVP + ` (event_id, user_id, intent, role)
+		VALUES (@eventID, @userID, 'yes', @role)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET intent='yes',
+			role=EXCLUDED.role,
+			waitlist_position=NULL,
+			updated_time=CURRENT_TIMESTAMP`
+	_, err = tx.Exec(ctx, q, pgx.NamedArgs{
+		"eventID": e.ID,
+		"userID":  userID,
+		"role":    role,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	promoted, err := promoteWaitlist(tx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, tx.Commit(ctx)
+}
+
+/*
+ * RemoveStaff takes the staff role away from a user. They're left with a
+ * maybe RSVP, since there might not be a spot for them as an attendee.
+ */
+func RemoveStaff(e *Event, userID uint64) error {
+
+	q := `UPDATE ` + DB_TABLE_RSVP + `
+		SET role='attendee', intent='maybe', updated_time=CURRENT_TIMESTAMP
+		WHERE event_id=@eventID AND user_id=@userID AND role <> 'attendee'`
+	_, err := e.DB.Exec(context.Background(), q, pgx.NamedArgs{
+		"eventID": e.ID,
+		"userID":  userID,
+	})
+
+	return err
+}
+
+/*
+ * GetStaffByEvent returns the hosts then crew of an event.
+ */
+func GetStaffByEvent(db *pgxpool.Pool, eventID uint64) ([]*RSVP, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_RSVP + ` WHERE event_id=@eventID AND role <> 'attendee'
+		ORDER BY role ASC, created_time ASC`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+	})
+
+	staff, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RSVP])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range staff {
+
+		r.DB = db
+
+		r.TheUser, err = GetUserByID(db, r.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return staff, nil
+}
@@ -0,0 +1,553 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DB_TABLE_TICKET_TYPES = "ticket_types"
+const DB_TABLE_ORDERS = "orders"
+
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderConfirmed OrderStatus = "confirmed"
+	OrderRefunded  OrderStatus = "refunded"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+/*
+ * TicketType is a kind of ticket sold for an event, such as early bird or
+ * student tickets. Events with ticket types take an order along with every
+ * RSVP.
+ */
+type TicketType struct {
+	framework.BaseModel
+	EventID uint64 `db:"event_id" validate:"required"`
+	Name    string `db:"name" validate:"required,max=100"`
+	// In the smallest unit of the currency, 0 for free tickets.
+	Price    int    `db:"price" validate:"min=0"`
+	Currency string `db:"currency" validate:"len=3,alpha,uppercase"`
+	// How many tickets can be sold, 0 meaning there's no limit.
+	Quantity int `db:"quantity" validate:"min=0"`
+	// Tickets can only be ordered between these times, when set.
+	SalesStart *time.Time `db:"sales_start"`
+	SalesEnd   *time.Time `db:"sales_end"`
+	// How many tickets pending and confirmed orders hold.
+	Sold int `db:"sold"`
+}
+
+/*
+ * Order is a user's purchase of tickets for themselves and their guests. It
+ * stays pending while the payment goes through, and only confirmed orders
+ * let people attend.
+ */
+type Order struct {
+	framework.BaseModel
+	// NULL for orders of deleted groups, which are only kept as a record and
+	// never loaded.
+	EventID       uint64      `db:"event_id"`
+	UserID        uint64      `db:"user_id"`
+	TheUser       *User       `db:"-"`
+	TicketTypeID  uint64      `db:"ticket_type_id"`
+	TheTicketType *TicketType `db:"-"`
+	Quantity      int         `db:"quantity"`
+	// What was charged, in the smallest unit of the currency.
+	Amount   int         `db:"amount"`
+	Currency string      `db:"currency"`
+	Status   OrderStatus `db:"status"`
+	// The payment provider used and its reference for the payment, empty for
+	// free orders.
+	Provider    string `db:"provider"`
+	ProviderRef string `db:"provider_ref"`
+}
+
+/*
+ * Available reports whether there are tickets left for that many people.
+ */
+func (t *TicketType) Available(spots int) bool {
+	return t.Quantity == 0 || t.Sold+spots <= t.Quantity
+}
+
+/*
+ * Delete removes the ticket type. Ticket types that were ordered can't be
+ * removed.
+ */
+func (t *TicketType) Delete() error {
+
+	var orders int
+	err := t.DB.QueryRow(context.Background(), `SELECT count(*) FROM `+DB_TABLE_ORDERS+` WHERE ticket_type_id=$1`, t.ID).Scan(&orders)
+	if err != nil {
+		return err
+	}
+
+	if orders > 0 {
+		return errors.New("Tickets of this type were already ordered.")
+	}
+
+	_, err = t.DB.Exec(context.Background(), `DELETE FROM `+t.table()+` WHERE id=$1`, t.ID)
+
+	return err
+}
+
+/*
+ * IsFree reports whether the ticket doesn't cost anything.
+ */
+func (t *TicketType) IsFree() bool { return t.Price == 0 }
+
+/*
+ * IsOnSale reports whether the ticket can be ordered at the time provided.
+ */
+func (t *TicketType) IsOnSale(now time.Time) bool {
+
+	if t.SalesStart != nil && now.Before(*t.SalesStart) {
+		return false
+	}
+
+	if t.SalesEnd != nil && !now.Before(*t.SalesEnd) {
+		return false
+	}
+
+	return true
+}
+
+/*
+ * PriceString returns the price of one ticket, e.g. "12.50 USD" or "Free".
+ */
+func (t *TicketType) PriceString() string {
+
+	if t.IsFree() {
+		return "Free"
+	}
+
+	return FormatPrice(t.Price, t.Currency)
+}
+
+/*
+ * Remaining returns how many tickets are left, -1 meaning there's no limit.
+ */
+func (t *TicketType) Remaining() int {
+
+	if t.Quantity == 0 {
+		return -1
+	}
+
+	return max(t.Quantity-t.Sold, 0)
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (t *TicketType) primaryKey() string { return "id" }
+
+/*
+ * table returns the table name used in the database.
+ */
+func (t *TicketType) table() string { return DB_TABLE_TICKET_TYPES }
+
+/*
+ * AmountString returns what was charged, e.g. "25.00 USD" or "Free".
+ */
+func (o *Order) AmountString() string {
+
+	if o.Amount == 0 {
+		return "Free"
+	}
+
+	return FormatPrice(o.Amount, o.Currency)
+}
+
+/*
+ * Cancel releases the tickets of an order that wasn't paid, or that was free.
+ */
+func (o *Order) Cancel() error {
+	return o.setStatus(OrderCancelled, o.ProviderRef)
+}
+
+/*
+ * Confirm marks the order as paid through the provider, with its reference
+ * for the payment. Free orders have neither.
+ */
+func (o *Order) Confirm(provider, ref string) error {
+
+	o.Provider = provider
+
+	return o.setStatus(OrderConfirmed, ref)
+}
+
+/*
+ * IsActive reports whether the order holds its tickets.
+ */
+func (o *Order) IsActive() bool {
+	return o.Status == OrderPending || o.Status == OrderConfirmed
+}
+
+/*
+ * IsPaid reports whether money was taken for the order, and so needs a refund
+ * to be released.
+ */
+func (o *Order) IsPaid() bool {
+	return o.Status == OrderConfirmed && o.Amount > 0 && o.Provider != ""
+}
+
+/*
+ * MarkRefunded releases the tickets of an order that was refunded by the
+ * payment provider.
+ */
+func (o *Order) MarkRefunded() error {
+	return o.setStatus(OrderRefunded, o.ProviderRef)
+}
+
+/*
+ * primaryKey returns the primary key name of the table
+ */
+func (o *Order) primaryKey() string { return "id" }
+
+/*
+ * setStatus saves the status of the order along with its payment details.
+ */
+func (o *Order) setStatus(status OrderStatus, ref string) error {
+
+	q := `UPDATE ` + o.table() + ` SET status=@status, provider=@provider, provider_ref=@ref, updated_time=@updatedTime WHERE id=@id`
+	_, err := o.DB.Exec(context.Background(), q, pgx.NamedArgs{
+		"status":      status,
+		"provider":    o.Provider,
+		"ref":         ref,
+		"updatedTime": time.Now().UTC(),
+		"id":          o.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save order (id:%d). Msg: %s", o.ID, err)
+	}
+
+	o.Status = status
+	o.ProviderRef = ref
+
+	return nil
+}
+
+/*
+ * table returns the table name used in the database.
+ */
+func (o *Order) table() string { return DB_TABLE_ORDERS }
+
+/*
+ * HasTickets reports whether the event sells tickets.
+ */
+func (e *Event) HasTickets() bool {
+	return len(e.TicketTypes()) > 0
+}
+
+/*
+ * TicketTypes returns the ticket types of the event.
+ */
+func (e *Event) TicketTypes() []*TicketType {
+
+	ticketTypes, err := GetTicketTypesByEvent(e.DB, e.ID)
+	if err != nil {
+		return nil
+	}
+
+	return ticketTypes
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+// ticketTypesQuery selects ticket types along with how many were sold.
+const ticketTypesQuery = `SELECT t.*, COALESCE((SELECT sum(o.quantity) FROM ` + DB_TABLE_ORDERS + ` o
+	WHERE o.ticket_type_id=t.id AND o.status IN ('pending', 'confirmed')), 0) AS sold
+	FROM ` + DB_TABLE_TICKET_TYPES + ` t`
+
+/*
+ * Internal init function.
+ */
+func initTicketType(db *pgxpool.Pool) *TicketType {
+
+	t := new(TicketType)
+	t.DB = db
+
+	return t
+}
+
+/*
+ * NewTicketType adds a ticket type to an event.
+ */
+func NewTicketType(db *pgxpool.Pool, eventID uint64, name string, price int, currency string, quantity int, salesStart, salesEnd *time.Time) (*TicketType, error) {
+
+	t := initTicketType(db)
+	t.EventID = eventID
+	t.Name = strings.TrimSpace(name)
+	t.Price = price
+	t.Currency = strings.ToUpper(strings.TrimSpace(currency))
+	t.Quantity = quantity
+
+	if salesStart != nil && salesEnd != nil && !salesEnd.After(*salesStart) {
+		return nil, errors.New("Ticket sales have to end after they start.")
+	}
+
+	err := validate.Struct(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if salesStart != nil {
+		start := salesStart.UTC()
+		t.SalesStart = &start
+	}
+
+	if salesEnd != nil {
+		end := salesEnd.UTC()
+		t.SalesEnd = &end
+	}
+
+	sql := `INSERT INTO ` + t.table() + ` (event_id, name, price, currency, quantity, sales_start, sales_end)
+		VALUES (@eventID, @name, @price, @currency, @quantity, @salesStart, @salesEnd) RETURNING *, 0 AS sold`
+	rows, _ := db.Query(context.Background(), sql, pgx.NamedArgs{
+		"eventID":    t.EventID,
+		"name":       t.Name,
+		"price":      t.Price,
+		"currency":   t.Currency,
+		"quantity":   t.Quantity,
+		"salesStart": t.SalesStart,
+		"salesEnd":   t.SalesEnd,
+	})
+
+	t, err = pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[TicketType])
+	if err != nil {
+		return nil, err
+	}
+	t.DB = db
+
+	return t, nil
+}
+
+/*
+ * GetTicketTypeByID returns the ticket type with the provided ID.
+ */
+func GetTicketTypeByID(db *pgxpool.Pool, id uint64) (*TicketType, error) {
+
+	rows, _ := db.Query(context.Background(), ticketTypesQuery+` WHERE t.id=$1`, id)
+
+	t, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[TicketType])
+	if err != nil {
+		return nil, err
+	}
+	t.DB = db
+
+	return t, nil
+}
+
+/*
+ * GetTicketTypesByEvent returns the ticket types of an event in the order
+ * they were added.
+ */
+func GetTicketTypesByEvent(db *pgxpool.Pool, eventID uint64) ([]*TicketType, error) {
+
+	rows, _ := db.Query(context.Background(), ticketTypesQuery+` WHERE t.event_id=$1 ORDER BY t.id`, eventID)
+
+	ticketTypes, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[TicketType])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range ticketTypes {
+		t.DB = db
+	}
+
+	return ticketTypes, nil
+}
+
+/*
+ * CopyTicketTypes adds the ticket types of an event to another one. The sales
+ * windows are tied to the date of the event so they aren't copied.
+ */
+func CopyTicketTypes(db *pgxpool.Pool, fromEventID, toEventID uint64) error {
+
+	q := `INSERT INTO ` + DB_TABLE_TICKET_TYPES + ` (event_id, name, price, currency, quantity)
+		SELECT @toEventID, name, price, currency, quantity FROM ` + DB_TABLE_TICKET_TYPES + `
+		WHERE event_id=@fromEventID ORDER BY id`
+	_, err := db.Exec(context.Background(), q, pgx.NamedArgs{
+		"fromEventID": fromEventID,
+		"toEventID":   toEventID,
+	})
+
+	return err
+}
+
+/*
+ * NewOrder holds tickets of a type for a user and that many people, in a
+ * pending order. The ticket type is locked while counting what's left so that
+ * the last tickets can't be sold twice.
+ */
+func NewOrder(t *TicketType, userID uint64, spots int, now time.Time) (*Order, error) {
+
+	if spots < 1 {
+		return nil, errors.New("An order needs at least one ticket.")
+	}
+
+	if !t.IsOnSale(now) {
+		return nil, errors.New("\"" + t.Name + "\" tickets aren't on sale.")
+	}
+
+	ctx := context.Background()
+
+	tx, err := t.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT id FROM `+DB_TABLE_TICKET_TYPES+` WHERE id=$1 FOR UPDATE`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sold int
+	q := `SELECT COALESCE(sum(quantity), 0) FROM ` + DB_TABLE_ORDERS + ` WHERE ticket_type_id=$1 AND status IN ('pending', 'confirmed')`
+	err = tx.QueryRow(ctx, q, t.ID).Scan(&sold)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Sold = sold
+	if !t.Available(spots) {
+		return nil, errors.New("There aren't enough \"" + t.Name + "\" tickets left.")
+	}
+
+	q = `INSERT INTO ` + DB_TABLE_ORDERS + ` (event_id, user_id, ticket_type_id, quantity, amount, currency)
+		VALUES (@eventID, @userID, @ticketTypeID, @quantity, @amount, @currency) RETURNING *`
+	rows, _ := tx.Query(ctx, q, pgx.NamedArgs{
+		"eventID":      t.EventID,
+		"userID":       userID,
+		"ticketTypeID": t.ID,
+		"quantity":     spots,
+		"amount":       t.Price * spots,
+		"currency":     t.Currency,
+	})
+
+	o, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Order])
+	if err != nil {
+		return nil, err
+	}
+	o.DB = t.DB
+	o.TheTicketType = t
+
+	t.Sold += spots
+
+	return o, tx.Commit(ctx)
+}
+
+/*
+ * GetOrderByID returns the order with the provided ID.
+ */
+func GetOrderByID(db *pgxpool.Pool, id uint64) (*Order, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_ORDERS+` WHERE id=$1`, id)
+
+	o, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Order])
+	if err != nil {
+		return nil, err
+	}
+	o.DB = db
+
+	return o, nil
+}
+
+/*
+ * GetActiveOrder returns the order holding tickets for a user's RSVP to an
+ * event. pgx.ErrNoRows is returned when there's none.
+ */
+func GetActiveOrder(db *pgxpool.Pool, eventID, userID uint64) (*Order, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_ORDERS + ` WHERE event_id=@eventID AND user_id=@userID
+		AND status IN ('pending', 'confirmed') ORDER BY id DESC LIMIT 1`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+		"userID":  userID,
+	})
+
+	o, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[Order])
+	if err != nil {
+		return nil, err
+	}
+	o.DB = db
+
+	return o, nil
+}
+
+/*
+ * GetOrdersByEvent returns every order of an event, newest first, along with
+ * who placed them and their ticket type.
+ */
+func GetOrdersByEvent(db *pgxpool.Pool, eventID uint64) ([]*Order, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT * FROM `+DB_TABLE_ORDERS+` WHERE event_id=$1 ORDER BY id DESC`, eventID)
+
+	orders, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[Order])
+	if err != nil {
+		return nil, err
+	}
+
+	ticketTypes, err := GetTicketTypesByEvent(db, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range orders {
+
+		o.DB = db
+
+		o.TheUser, err = GetUserByID(db, o.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range ticketTypes {
+			if t.ID == o.TicketTypeID {
+				o.TheTicketType = t
+			}
+		}
+	}
+
+	return orders, nil
+}
+
+/*
+ * FormatPrice returns an amount in the smallest unit of the currency as a
+ * decimal, e.g. "12.50 USD".
+ */
+func FormatPrice(amount int, currency string) string {
+	return fmt.Sprintf("%d.%02d %s", amount/100, amount%100, currency)
+}
+
+/*
+ * ParsePrice reads a decimal price, such as "12.5", into the smallest unit of
+ * the currency. An empty price is free.
+ */
+func ParsePrice(price string) (int, error) {
+
+	price = strings.TrimSpace(price)
+	if price == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(price, 64)
+	if err != nil || f < 0 || f > 1000000 {
+		return 0, errors.New("The price \"" + price + "\" isn't valid.")
+	}
+
+	return int(math.Round(f * 100)), nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Times are stored in UTC in timestamp columns. Each event also has the IANA
+ * timezone it takes place in, which is used to show its local time and to
+ * keep the wall clock time of recurring and copied events across DST changes.
+ */
+
+/*
+ * loadLocation returns the location of an IANA timezone, UTC if it's unknown.
+ */
+func loadLocation(name string) *time.Location {
+
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" {
+		return time.UTC
+	}
+
+	return loc
+}
+
+/*
+ * WallClockEnd returns the end of an event starting at start that lasts, on
+ * the wall clock of loc, as long as the one from origStart to origEnd. That
+ * is the same number of days later at the same time of day, which isn't
+ * always the same duration when a DST change happens in between.
+ */
+func WallClockEnd(start, origStart, origEnd time.Time, loc *time.Location) time.Time {
+
+	from, to, s := origStart.In(loc), origEnd.In(loc), start.In(loc)
+
+	days := int(time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	end := time.Date(s.Year(), s.Month(), s.Day()+days, to.Hour(), to.Minute(), to.Second(), to.Nanosecond(), loc)
+
+	// An end falling in a DST gap can be normalized before the start.
+	if !end.After(s) {
+		return s.Add(origEnd.Sub(origStart)).UTC()
+	}
+
+	return end.UTC()
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * GetCityTimezone returns the timezone of a city, empty if it doesn't have
+ * one.
+ */
+func GetCityTimezone(db *pgxpool.Pool, cityID uint64) (string, error) {
+
+	var timezone *string
+
+	q := `SELECT timezone FROM ` + DB_TABLE_CITY + ` WHERE id=@id`
+	err := db.QueryRow(context.Background(), q, pgx.NamedArgs{
+		"id": cityID,
+	}).Scan(&timezone)
+	if err != nil {
+		return "", err
+	}
+
+	if timezone == nil {
+		return "", nil
+	}
+
+	return *timezone, nil
+}
+
+/*
+ * GetTimezoneNames returns the names of the timezones cities can be in.
+ */
+func GetTimezoneNames(db *pgxpool.Pool) ([]string, error) {
+
+	rows, _ := db.Query(context.Background(), `SELECT name FROM timezones ORDER BY name`)
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
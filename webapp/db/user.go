@@ -26,6 +26,10 @@ type User struct {
 	FirstName  string    `db:"first_name"`
 	LastName   string    `db:"last_name"`
 	LastActive time.Time `db:"last_active"`
+	// Home city, the default location of "near me" searches
+	CityID *uint64 `db:"city_id"`
+	// Whether hosts of events the user RSVP'd to can see their email address
+	ShareEmail bool `db:"share_email"`
 }
 
 /*
@@ -106,6 +110,42 @@ func (u *User) save() error {
 	return nil
 }
 
+/*
+ * SetHomeCity changes the user's home city. nil removes it.
+ */
+func (u *User) SetHomeCity(cityID *uint64) error {
+
+	_, err := u.DB.Exec(context.Background(), `UPDATE `+u.table()+` SET city_id=@cityID WHERE id=@id`, pgx.NamedArgs{
+		"cityID": cityID,
+		"id":     u.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save home city of user (id:%d). Msg: %s", u.ID, err)
+	}
+
+	u.CityID = cityID
+
+	return nil
+}
+
+/*
+ * SetShareEmail changes whether hosts can see the user's email address.
+ */
+func (u *User) SetShareEmail(share bool) error {
+
+	_, err := u.DB.Exec(context.Background(), `UPDATE `+u.table()+` SET share_email=@share WHERE id=@id`, pgx.NamedArgs{
+		"share": share,
+		"id":    u.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to save privacy of user (id:%d). Msg: %s", u.ID, err)
+	}
+
+	u.ShareEmail = share
+
+	return nil
+}
+
 func (u *User) table() string { return "users" }
 
 /*
@@ -246,6 +286,29 @@ func GetUserByUsername(db *pgxpool.Pool, username string) (*User, error) {
 	return GetUserBy(db, "username='"+username+"'")
 }
 
+/*
+ * GetUserByUsernameOrEmail finds a user by their username or by any of their
+ * email addresses.
+ */
+func GetUserByUsernameOrEmail(db *pgxpool.Pool, value string) (*User, error) {
+
+	q := `SELECT * FROM users WHERE username=@value
+		OR id IN (SELECT user_id FROM email_addresses WHERE the_value=@value)
+		LIMIT 1`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"value": value,
+	})
+	u, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[User])
+	if err != nil {
+
+		return nil, fmt.Errorf("Failed to get user from the DB. Msg: %s", err)
+	}
+
+	u.DB = db
+
+	return u, nil
+}
+
 func GetUsers(db *pgxpool.Pool, start int, count int) ([]*User, error) {
 
 	q := `SELECT * FROM users WHERE LIMIT @limit OFFSET @offset`
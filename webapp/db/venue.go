@@ -35,11 +35,12 @@ func (g *venue) primaryKey() string { return "id" }
  */
 func (v *venue) save() error {
 
-	_, err := v.DB.Exec(context.Background(), "UPDATE "+v.table()+" SET name = $1, address = $2, city_id = $3, web_url = $4, updated_time = $5 WHERE "+v.primaryKey()+" = $6",
+	_, err := v.DB.Exec(context.Background(), "UPDATE "+v.table()+" SET name = $1, address = $2, city_id = $3, web_url = $4, capacity = $5, updated_time = $6 WHERE "+v.primaryKey()+" = $7",
 		v.Name,
 		v.Address,
 		v.CityID,
 		v.WebURL,
+		v.Capacity,
 		v.UpdatedTime,
 		v.ID,
 	)
@@ -88,6 +89,27 @@ func GetVenueByID(db *pgxpool.Pool, id uint64) (*venue, error) {
 	return v, nil
 }
 
+/*
+ * GetVenueByGroupAndName returns a venue one of the events of a group took
+ * place at, by name. Names are compared without case.
+ */
+func GetVenueByGroupAndName(db *pgxpool.Pool, groupID uint64, name string) (*venue, error) {
+
+	v := initVenue(db)
+
+	q := `SELECT v.* FROM ` + v.table() + ` v WHERE lower(v.name)=lower($2) AND EXISTS (
+		SELECT 1 FROM ` + DB_TABLE_EVENT + ` e WHERE e.venue_id=v.id AND e.group_id=$1)
+		ORDER BY v.id LIMIT 1`
+	rows, _ := db.Query(context.Background(), q, groupID, name)
+	v, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[venue])
+	if err != nil {
+		return nil, err
+	}
+	v.DB = db
+
+	return v, nil
+}
+
 /*
  * Internal init function.
  */
@@ -100,14 +122,15 @@ func initVenue(db *pgxpool.Pool) *venue {
 }
 
 /*
- * NewVenue creates a new Venue in the DB.
+ * NewVenue creates a new Venue in the DB. A capacity of 0 means it's unknown.
  */
-func NewVenue(db *pgxpool.Pool, name, address string, cityID uint64) (*venue, error) {
+func NewVenue(db *pgxpool.Pool, name, address string, cityID uint64, capacity uint) (*venue, error) {
 
 	v := initVenue(db)
 	v.Name = name
 	v.Address = address
 	v.CityID = cityID
+	v.Capacity = capacity
 
 	// validate inputs
 	errs := validate.Var(name, "required,min=3,max=26")
@@ -119,11 +142,12 @@ func NewVenue(db *pgxpool.Pool, name, address string, cityID uint64) (*venue, er
 		return nil, errs
 	}
 
-	q := `INSERT INTO ` + v.table() + ` (name, address, city_id, web_url, capacity) VALUES (@name, @address, @cityID, '', 0) RETURNING *`
+	q := `INSERT INTO ` + v.table() + ` (name, address, city_id, web_url, capacity) VALUES (@name, @address, @cityID, '', @capacity) RETURNING *`
 	rows, _ := v.DB.Query(context.Background(), q, pgx.NamedArgs{
-		"name":    v.Name,
-		"address": v.Address,
-		"cityID":  v.CityID,
+		"name":     v.Name,
+		"address":  v.Address,
+		"cityID":   v.CityID,
+		"capacity": v.Capacity,
 	})
 
 	v, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[venue])
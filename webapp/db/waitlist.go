@@ -0,0 +1,349 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * Capacity enforcement. Hosts and crew don't take a spot, only attendees do.
+ * Every change happens in a transaction that locks the event row so that two
+ * people can't get the last spot at the same time.
+ */
+
+/*
+ * SetRSVP saves a user's intent for an event, along with the guests they
+ * bring. A "yes" that doesn't fit on its track, guests included, goes at the
+ * end of the waitlist. Someone who already has a spot keeps it and can only
+ * add guests if there's room for them. Anyone giving up their spot makes room for the
+ * waitlist, which is promoted in order. The promoted RSVPs are returned so
+ * that they can be notified.
+ */
+func SetRSVP(e *Event, u *User, intent RSVPStatus, guests int, guestNames []string) (*RSVP, []*RSVP, error) {
+
+	intent = e.Intent(intent)
+
+	if !intent.IsAttending() {
+		guests = 0
+		guestNames = nil
+	}
+
+	if guests < 0 || guests > e.GuestLimit {
+		return nil, nil, fmt.Errorf("You can bring up to %d guests.", e.GuestLimit)
+	}
+
+	names := []string{}
+	for _, name := range guestNames {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) > guests {
+		return nil, nil, errors.New("There are more guest names than guests.")
+	}
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	q := `SELECT * FROM ` + DB_TABLE_RSVP + ` WHERE event_id=@eventID AND user_id=@userID`
+	rows, _ := tx.Query(ctx, q, pgx.NamedArgs{
+		"eventID": e.ID,
+		"userID":  u.ID,
+	})
+
+	r, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByName[RSVP])
+	if err == pgx.ErrNoRows {
+
+		r = initRSVP(e.DB)
+		r.EventID = e.ID
+		r.UserID = u.ID
+		r.Role = RSVPAttendee
+	} else if err != nil {
+		return nil, nil, err
+	}
+	r.DB = e.DB
+
+	// Switching to the other track of a hybrid event means getting a spot
+	// there.
+	hadSpot := r.IsConfirmed() && e.Track(r.Intent) == e.Track(intent)
+	hadSpots := r.Spots()
+	r.Intent = intent
+	r.Guests = guests
+	r.GuestNames = names
+
+	err = validate.Struct(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	track := e.Track(intent)
+
+	if !intent.IsAttending() {
+
+		r.WaitlistPosition = nil
+	} else if r.Role == RSVPAttendee && e.TrackCapacity(track) > 0 && ((!hadSpot && !r.IsWaitlisted()) || (hadSpot && r.Spots() > hadSpots)) {
+
+		counts, err := countTracks(tx, e.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		taken := counts.Of(track)
+		if hadSpot {
+			taken -= hadSpots
+		}
+
+		if taken+r.Spots() > e.TrackCapacity(track) && hadSpot {
+			return nil, nil, errors.New("There isn't enough room left for your guests.")
+		}
+
+		if taken+r.Spots() > e.TrackCapacity(track) {
+
+			var position int
+			err = tx.QueryRow(ctx, `SELECT COALESCE(MAX(waitlist_position), 0) + 1 FROM `+DB_TABLE_RSVP+` WHERE event_id=$1`, e.ID).Scan(&position)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			r.WaitlistPosition = &position
+		}
+	}
+
+	q = `INSERT INTO ` + DB_TABLE_RSVP + ` (event_id, user_id, intent, role, waitlist_position, guests, guest_names)
+		VALUES (@eventID, @userID, @intent, @role, @waitlistPosition, @guests, @guestNames)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET intent=EXCLUDED.intent,
+			waitlist_position=EXCLUDED.waitlist_position,
+			guests=EXCLUDED.guests,
+			guest_names=EXCLUDED.guest_names,
+			updated_time=CURRENT_TIMESTAMP`
+	_, err = tx.Exec(ctx, q, pgx.NamedArgs{
+		"eventID":          r.EventID,
+		"userID":           r.UserID,
+		"intent":           r.Intent,
+		"role":             r.Role,
+		"waitlistPosition": r.WaitlistPosition,
+		"guests":           r.Guests,
+		"guestNames":       r.GuestNames,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	promoted, err := promoteWaitlist(tx, e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, promoted, tx.Commit(ctx)
+}
+
+/*
+ * PromoteWaitlist gives free spots to the waitlist, for example after the
+ * attendee limit was raised. The promoted RSVPs are returned.
+ */
+func PromoteWaitlist(e *Event) ([]*RSVP, error) {
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	promoted, err := promoteWaitlist(tx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, tx.Commit(ctx)
+}
+
+/*
+ * MoveOnWaitlist swaps a user with the person before (up) or after them on
+ * the waitlist.
+ */
+func MoveOnWaitlist(e *Event, userID uint64, up bool) error {
+
+	ctx := context.Background()
+
+	tx, err := e.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockEvent(tx, e.ID)
+	if err != nil {
+		return err
+	}
+
+	var position *int
+	err = tx.QueryRow(ctx, `SELECT waitlist_position FROM `+DB_TABLE_RSVP+` WHERE event_id=$1 AND user_id=$2`, e.ID, userID).Scan(&position)
+	if err != nil {
+		return err
+	}
+
+	if position == nil {
+		return errors.New("User is not on the waitlist.")
+	}
+
+	q := `SELECT user_id, waitlist_position FROM ` + DB_TABLE_RSVP + ` WHERE event_id=$1 AND waitlist_position < $2 ORDER BY waitlist_position DESC LIMIT 1`
+	if !up {
+		q = `SELECT user_id, waitlist_position FROM ` + DB_TABLE_RSVP + ` WHERE event_id=$1 AND waitlist_position > $2 ORDER BY waitlist_position ASC LIMIT 1`
+	}
+
+	var otherID uint64
+	var otherPosition int
+	err = tx.QueryRow(ctx, q, e.ID, *position).Scan(&otherID, &otherPosition)
+	if err == pgx.ErrNoRows {
+		// already first or last
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	q = `UPDATE ` + DB_TABLE_RSVP + ` SET waitlist_position=$1, updated_time=CURRENT_TIMESTAMP WHERE event_id=$2 AND user_id=$3`
+
+	_, err = tx.Exec(ctx, q, otherPosition, e.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, q, *position, e.ID, otherID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+/*
+ * GetWaitlistByEvent returns the waitlisted RSVPs of an event, in order.
+ */
+func GetWaitlistByEvent(db *pgxpool.Pool, eventID uint64) ([]*RSVP, error) {
+
+	q := `SELECT * FROM ` + DB_TABLE_RSVP + ` WHERE event_id=@eventID AND waitlist_position IS NOT NULL ORDER BY waitlist_position`
+	rows, _ := db.Query(context.Background(), q, pgx.NamedArgs{
+		"eventID": eventID,
+	})
+
+	rsvps, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RSVP])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, aRSVP := range rsvps {
+
+		aRSVP.DB = db
+
+		aRSVP.TheUser, err = GetUserByID(db, aRSVP.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rsvps, nil
+}
+
+/*
+ * CountConfirmedByEvent returns how many spots are taken, guests included.
+ */
+func CountConfirmedByEvent(db *pgxpool.Pool, eventID uint64) (int, error) {
+
+	var count int
+	err := db.QueryRow(context.Background(), confirmedQuery, eventID).Scan(&count)
+
+	return count, err
+}
+
+const confirmedQuery = `SELECT COALESCE(sum(1 + guests), 0) FROM ` + DB_TABLE_RSVP + `
+	WHERE event_id=$1 AND role='attendee' AND intent IN ('yes', 'in-person', 'online') AND waitlist_position IS NULL`
+
+/*
+ * lockEvent locks the event row until the end of the transaction.
+ */
+func lockEvent(tx pgx.Tx, eventID uint64) error {
+
+	_, err := tx.Exec(context.Background(), `SELECT id FROM `+DB_TABLE_EVENT+` WHERE id=$1 FOR UPDATE`, eventID)
+
+	return err
+}
+
+/*
+ * promoteWaitlist moves people from the waitlist to the attendees, in order,
+ * while there's room on their track.
+ */
+func promoteWaitlist(tx pgx.Tx, e *Event) ([]*RSVP, error) {
+
+	ctx := context.Background()
+
+	q := `SELECT * FROM ` + DB_TABLE_RSVP + ` WHERE event_id=$1 AND waitlist_position IS NOT NULL ORDER BY waitlist_position`
+	rows, _ := tx.Query(ctx, q, e.ID)
+	waitlist, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[RSVP])
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := countTracks(tx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[RSVPStatus]int{
+		RSVPYes:      counts.Of(RSVPYes),
+		RSVPInPerson: counts.InPerson,
+		RSVPOnline:   counts.Online,
+	}
+
+	var promoted []*RSVP
+
+	for _, r := range waitlist {
+
+		// A full track doesn't hold back the other one, and a party too big
+		// for the spots left doesn't hold back smaller ones after it.
+		track := e.Track(r.Intent)
+		if e.TrackCapacity(track) > 0 && taken[track]+r.Spots() > e.TrackCapacity(track) {
+			continue
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE `+DB_TABLE_RSVP+` SET waitlist_position=NULL, updated_time=CURRENT_TIMESTAMP WHERE event_id=$1 AND user_id=$2`, e.ID, r.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		r.DB = e.DB
+		r.WaitlistPosition = nil
+		r.TheUser, err = GetUserByID(e.DB, r.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		promoted = append(promoted, r)
+		taken[track] += r.Spots()
+	}
+
+	return promoted, nil
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/eventhunt-org/webapp/webapp/db"
 
@@ -110,3 +116,315 @@ func sendEmailVerification(email, code string) error {
 
 	return smtp.SendMail(host+":"+port, auth, from, []string{email}, message)
 }
+
+func sendEmailEventChanged(u *db.User, e *db.Event, change string) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - " + e.Name + " has changed\r\n" +
+		"\r\n" +
+		"The " + change + " for " + e.Name + " has changed. The event is now:" + "\r\n" +
+		"\r\n" +
+		"Time: " + e.SmartTime() + "\r\n" +
+		"Place: " + e.Place() + "\r\n" +
+		"\r\n" +
+		"View the event: https://" + hostname + "/events/" + e.IDString() + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an event changed email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+func sendEmailEventCancelled(u *db.User, e *db.Event, reason string) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - " + e.Name + " is cancelled\r\n" +
+		"\r\n" +
+		e.Name + " on " + e.SmartTime() + " has been cancelled." + "\r\n" +
+		"\r\n" +
+		reason + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an event cancelled email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+func sendEmailWaitlistPromoted(u *db.User, e *db.Event, ticket []byte) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	headers := "To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - You're off the waitlist for " + e.Name + "\r\n"
+	body := "A spot opened up for " + e.Name + " and it's yours. See you there!" + "\r\n" +
+		"\r\n" +
+		"Time: " + e.SmartTime() + "\r\n" +
+		"Place: " + e.Place() + "\r\n" +
+		"\r\n" +
+		ticketNote(ticket) +
+		"If you can't make it anymore, please change your RSVP so someone else" + "\r\n" +
+		"can have the spot: https://" + hostname + "/events/" + e.IDString() + "\r\n"
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing a waitlist promotion email here:")
+		log.Info(headers + "\r\n" + body)
+
+		return nil
+	}
+
+	message := emailWithTicket(headers, body, ticket)
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+func sendEmailRSVPConfirmed(u *db.User, e *db.Event, ticket []byte) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	headers := "To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - You're going to " + e.Name + "\r\n"
+	body := "Your spot for " + e.Name + " is confirmed. See you there!" + "\r\n" +
+		"\r\n" +
+		"Time: " + e.SmartTime() + "\r\n" +
+		"Place: " + e.Place() + "\r\n" +
+		"\r\n" +
+		ticketNote(ticket) +
+		"If your plans change, please update your RSVP: https://" + hostname + "/events/" + e.IDString() + "\r\n"
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an RSVP confirmation email here:")
+		log.Info(headers + "\r\n" + body)
+
+		return nil
+	}
+
+	message := emailWithTicket(headers, body, ticket)
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+func sendEmailEventReminder(u *db.User, e *db.Event, offset time.Duration) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	when := "in " + strconv.Itoa(int(offset.Hours())) + " hours"
+	if offset >= 48*time.Hour {
+		when = "in " + strconv.Itoa(int(offset.Hours()/24)) + " days"
+	} else if offset >= 24*time.Hour {
+		when = "tomorrow"
+	} else if offset < 2*time.Hour {
+		when = "soon"
+	}
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - Reminder: " + e.Name + " is " + when + "\r\n" +
+		"\r\n" +
+		"This is a reminder that " + e.Name + " is " + when + "." + "\r\n" +
+		"\r\n" +
+		"Time: " + e.SmartTime() + "\r\n" +
+		"Place: " + e.Place() + "\r\n" +
+		"\r\n" +
+		"If your plans changed, please update your RSVP: https://" + hostname + "/events/" + e.IDString() + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an event reminder email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+func sendEmailEventPostponed(u *db.User, e *db.Event, reason string) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - " + e.Name + " is postponed\r\n" +
+		"\r\n" +
+		e.Name + " on " + e.SmartTime() + " has been postponed. The new date will be" + "\r\n" +
+		"announced by the host." + "\r\n" +
+		"\r\n" +
+		reason + "\r\n" +
+		"\r\n" +
+		"View the event: https://" + hostname + "/events/" + e.IDString() + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an event postponed email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+/*
+ * Email someone that took part in a comment thread about a new reply.
+ */
+func sendEmailCommentReply(u *db.User, e *db.Event, c *db.Comment) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - New reply on " + e.Name + "\r\n" +
+		"\r\n" +
+		c.TheUser.Username + " replied in a conversation you're part of on " + e.Name + ":" + "\r\n" +
+		"\r\n" +
+		c.Body + "\r\n" +
+		"\r\n" +
+		"Reply: https://" + hostname + "/events/" + e.IDString() + "#comment-" + c.IDString() + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing a comment reply email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+/*
+ * Email an attendee a message from a host of the event.
+ */
+func sendEmailEventMessage(u *db.User, e *db.Event, sender *db.User, subject, body string) error {
+
+	from := "notifications@" + HostnameEmail
+	username := os.Getenv("SMTP_USER")
+	password := os.Getenv("SMTP_PWD")
+	host := os.Getenv("SMTP_HOST")
+	port := "587"
+
+	message := []byte("To: " + u.Email() + "\r\n" +
+		"From: " + AppName + " <" + from + ">\r\n" +
+		"Subject: " + AppName + " - " + e.Name + ": " + subject + "\r\n" +
+		"\r\n" +
+		sender.Username + " sent a message to the attendees of " + e.Name + ":" + "\r\n" +
+		"\r\n" +
+		body + "\r\n" +
+		"\r\n" +
+		"View the event: https://" + hostname + "/events/" + e.IDString() + "\r\n")
+
+	if environment == "development" {
+		log.Info("We're not in production so outputing an event message email here:")
+		log.Info(string(message))
+
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", username, password, host)
+
+	return smtp.SendMail(host+":"+port, auth, from, []string{u.Email()}, message)
+}
+
+// ticketNote tells attendees about the QR ticket attached to an email, if any.
+func ticketNote(ticket []byte) string {
+
+	if ticket == nil {
+		return ""
+	}
+
+	return "Your ticket is attached, show its QR code at the door to check in." + "\r\n" +
+		"\r\n"
+}
+
+// emailWithTicket returns the email made of headers and a plain text body,
+// with the PNG of a QR ticket attached when there's one.
+func emailWithTicket(headers, body string, ticket []byte) []byte {
+
+	if ticket == nil {
+		return []byte(headers + "\r\n" + body)
+	}
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+
+	b.WriteString(headers +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=" + mw.Boundary() + "\r\n" +
+		"\r\n")
+
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	part.Write([]byte(body))
+
+	part, _ = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"image/png"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="ticket.png"`},
+	})
+
+	// base64 lines can't be longer than 76 characters
+	encoded := base64.StdEncoding.EncodeToString(ticket)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	part.Write([]byte(encoded + "\r\n"))
+
+	mw.Close()
+
+	return b.Bytes()
+}
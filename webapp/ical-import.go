@@ -0,0 +1,574 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/teambition/rrule-go"
+)
+
+/*
+ * iCalendar (RFC 5545) import. Each VEVENT of a file becomes an event of the
+ * group, recurring ones becoming one event per occurrence up to the series
+ * horizon, so that years of history can be brought over. Events remember the
+ * UID they had in the file so importing it again skips them.
+ */
+
+// icalImportMax is the most events a single file can import.
+const icalImportMax = 500
+
+// icalImportMaxSize is the largest file that can be imported, in bytes.
+const icalImportMaxSize = 2 << 20
+
+// icalDuration matches the RFC 5545 durations we support, such as PT1H30M.
+var icalDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+/*
+ * icalImportEvent is an event read from an iCalendar file, as it will be
+ * created. When Skip is set, it's the reason the event won't be imported.
+ */
+type icalImportEvent struct {
+	UID         string
+	Name        string
+	Description string
+	WebURL      string
+	// Start and End are in Timezone.
+	Start     time.Time
+	End       time.Time
+	Timezone  string
+	Cancelled bool
+	// Location is the LOCATION of the event as written in the file. It's
+	// mapped to a venue, an existing one when VenueID is set, or to
+	// LocationURL.
+	Location     string
+	VenueID      *uint64
+	VenueName    string
+	VenueAddress string
+	LocationURL  string
+	Skip         string
+}
+
+/*
+ * Place describes where the imported event will take place.
+ */
+func (ie *icalImportEvent) Place() string {
+
+	var place []string
+
+	if ie.VenueName != "" {
+
+		venue := ie.VenueName + ", " + ie.VenueAddress
+		if ie.VenueID == nil {
+			venue = venue + " (new venue)"
+		}
+		place = append(place, venue)
+	}
+
+	if ie.LocationURL != "" {
+		place = append(place, ie.LocationURL)
+	}
+
+	if len(place) == 0 {
+		return "to be determined"
+	}
+
+	return strings.Join(place, " and ")
+}
+
+/*
+ * parseICalImport reads the events of an iCalendar file. Times without a
+ * timezone are taken to be in the X-WR-TIMEZONE of the calendar, or else in
+ * fallback. Events are returned in the order they start, at most
+ * icalImportMax of them.
+ */
+func parseICalImport(r io.Reader, fallback string) ([]*icalImportEvent, error) {
+
+	cal, err := ics.ParseCalendar(r)
+	if err != nil {
+		return nil, errors.New("The file isn't a valid iCalendar file.")
+	}
+
+	defaultLoc := loadICalLocation(fallback, nil)
+	for _, p := range cal.CalendarProperties {
+		if p.IANAToken == "X-WR-TIMEZONE" {
+			defaultLoc = loadICalLocation(p.Value, defaultLoc)
+		}
+	}
+
+	// Occurrences of recurring events that were changed are their own
+	// VEVENTs, with the UID of the event and the RECURRENCE-ID they replace.
+	overrides := map[string]*ics.VEvent{}
+	for _, ev := range cal.Events() {
+
+		recurrence := ev.GetProperty(ics.ComponentPropertyRecurrenceId)
+		if recurrence == nil {
+			continue
+		}
+
+		t, _, _, err := parseICalTime(recurrence, defaultLoc)
+		if err != nil {
+			continue
+		}
+
+		overrides[icalOccurrenceUID(ev.Id(), t)] = ev
+	}
+
+	var events []*icalImportEvent
+	horizon := time.Now().Add(db.SeriesHorizon)
+
+	for _, ev := range cal.Events() {
+
+		if ev.HasProperty(ics.ComponentPropertyRecurrenceId) {
+			continue
+		}
+
+		ie := newICalImportEvent(ev, ev.Id(), defaultLoc)
+
+		rule := ev.GetProperty(ics.ComponentPropertyRrule)
+		if rule == nil || ie.Skip != "" {
+			events = append(events, ie)
+			continue
+		}
+
+		loc := ie.Start.Location()
+		opt, err := db.ParseRRule(rule.Value, loc)
+		if err != nil {
+			ie.Skip = err.Error()
+			events = append(events, ie)
+			continue
+		}
+		opt.Dtstart = ie.Start
+
+		rr, err := rrule.NewRRule(*opt)
+		if err != nil {
+			ie.Skip = "The repeat rule isn't valid."
+			events = append(events, ie)
+			continue
+		}
+
+		set := &rrule.Set{}
+		set.RRule(rr)
+		for _, p := range ev.GetProperties(ics.ComponentPropertyExdate) {
+			for _, t := range parseICalTimes(p, loc) {
+				set.ExDate(t)
+			}
+		}
+
+		for _, start := range set.Between(ie.Start, horizon, true) {
+
+			uid := icalOccurrenceUID(ev.Id(), start)
+
+			if override, ok := overrides[uid]; ok {
+				delete(overrides, uid)
+				events = append(events, newICalImportEvent(override, uid, defaultLoc))
+				continue
+			}
+
+			occurrence := *ie
+			occurrence.UID = uid
+			occurrence.Start = start
+			occurrence.End = db.WallClockEnd(start, ie.Start, ie.End, loc)
+			events = append(events, &occurrence)
+
+			if len(events) > icalImportMax {
+				break
+			}
+		}
+	}
+
+	// changed occurrences of events that aren't in the file
+	for uid, ev := range overrides {
+		events = append(events, newICalImportEvent(ev, uid, defaultLoc))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+
+	if len(events) > icalImportMax {
+		events = events[:icalImportMax]
+	}
+
+	return events, nil
+}
+
+/*
+ * planICalImport decides which of the events read from a file are imported
+ * into the group and how their locations are mapped. Events that were
+ * imported before, that were exported from the group by EventHunt, or that
+ * appear twice in the file are skipped.
+ */
+func (a *app) planICalImport(g *db.Group, events []*icalImportEvent) error {
+
+	imported, err := db.GetImportedUIDs(a.DB, g.ID)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+
+	for _, ie := range events {
+
+		if ie.Skip != "" {
+			continue
+		}
+
+		switch {
+		case len(ie.UID) > 512:
+			ie.Skip = "The UID is too long."
+		case imported[ie.UID]:
+			ie.Skip = "Already imported."
+		case seen[ie.UID]:
+			ie.Skip = "Appears twice in the file."
+		case a.isGroupEventUID(g, ie.UID):
+			ie.Skip = "Already an event of the group."
+		case utf8.RuneCountInString(ie.Name) < 3:
+			ie.Skip = "The name is too short."
+		}
+		seen[ie.UID] = true
+
+		if ie.Skip != "" || ie.VenueName == "" {
+			continue
+		}
+
+		v, err := db.GetVenueByGroupAndName(a.DB, g.ID, ie.VenueName)
+		if err == nil {
+			ie.VenueID = &v.ID
+			ie.VenueName = v.Name
+			ie.VenueAddress = v.Address
+			continue
+		}
+
+		// the same limits as when creating a venue, otherwise the location
+		// is only kept in the description
+		nameLen := utf8.RuneCountInString(ie.VenueName)
+		addressLen := utf8.RuneCountInString(ie.VenueAddress)
+		if nameLen < 3 || nameLen > 26 || addressLen < 3 || addressLen > 40 {
+
+			ie.VenueName = ""
+			ie.VenueAddress = ""
+			ie.Description = strings.TrimSpace("Location: " + ie.Location + "\n\n" + ie.Description)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * importICal creates the events planned by planICalImport in the group. New
+ * venues are created in the city of the group. Imported events are drafts
+ * unless publish is set. It returns how many events were imported and the
+ * last error, if any, as one failing event doesn't stop the others.
+ */
+func (a *app) importICal(u *db.User, g *db.Group, events []*icalImportEvent, publish bool) (int, error) {
+
+	var lastErr error
+	count := 0
+	venues := map[string]uint64{}
+
+	for _, ie := range events {
+
+		if ie.Skip != "" {
+			continue
+		}
+
+		venueID := ie.VenueID
+		if venueID == nil && ie.VenueName != "" {
+
+			key := strings.ToLower(ie.VenueName)
+			if id, ok := venues[key]; ok {
+				venueID = &id
+			} else {
+
+				v, err := db.NewVenue(a.DB, ie.VenueName, ie.VenueAddress, g.CityID, 0)
+				if err != nil {
+					slog.Error("Failed to create imported venue.", "groupID", g.ID, "venue", ie.VenueName, "err", err)
+					lastErr = err
+					continue
+				}
+
+				venues[key] = v.ID
+				venueID = &v.ID
+			}
+		}
+
+		imported := &db.Event{
+			GroupID:     g.ID,
+			Name:        ie.Name,
+			StartTime:   ie.Start,
+			EndTime:     ie.End,
+			Timezone:    ie.Timezone,
+			Description: ie.Description,
+			WebURL:      ie.WebURL,
+			VenueID:     venueID,
+			LocationURL: ie.LocationURL,
+			Status:      db.EventDraft,
+		}
+		if ie.Cancelled {
+			imported.Status = db.EventCancelled
+		} else if publish {
+			imported.Status = db.EventPublished
+		}
+
+		_, err := db.NewImportedEvent(u, ie.UID, imported)
+		if err != nil {
+			slog.Error("Failed to import event.", "groupID", g.ID, "uid", ie.UID, "err", err)
+			lastErr = err
+			continue
+		}
+
+		count++
+	}
+
+	return count, lastErr
+}
+
+/*
+ * isGroupEventUID reports whether uid is the UID EventHunt gives one of the
+ * events of the group in its own iCalendar feeds.
+ */
+func (a *app) isGroupEventUID(g *db.Group, uid string) bool {
+
+	idStr, ok := strings.CutPrefix(uid, "event-")
+	if !ok {
+		return false
+	}
+
+	idStr, ok = strings.CutSuffix(idStr, "@"+HostnameBase)
+	if !ok {
+		return false
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	e, err := db.GetEventByID(a.DB, id)
+
+	return err == nil && e.GroupID == g.ID
+}
+
+/*
+ * newICalImportEvent reads a VEVENT. Events without a valid start time get
+ * Skip set.
+ */
+func newICalImportEvent(ev *ics.VEvent, uid string, defaultLoc *time.Location) *icalImportEvent {
+
+	ie := &icalImportEvent{UID: uid}
+
+	if p := ev.GetProperty(ics.ComponentPropertySummary); p != nil {
+		ie.Name = strings.TrimSpace(p.Value)
+	}
+	if utf8.RuneCountInString(ie.Name) > 80 {
+		ie.Name = string([]rune(ie.Name)[:77]) + "..."
+	}
+
+	if p := ev.GetProperty(ics.ComponentPropertyDescription); p != nil {
+		ie.Description = strings.TrimSpace(p.Value)
+	}
+
+	if p := ev.GetProperty(ics.ComponentPropertyUrl); p != nil && isWebURL(p.Value) {
+		ie.WebURL = p.Value
+	}
+
+	if p := ev.GetProperty(ics.ComponentPropertyStatus); p != nil {
+		ie.Cancelled = strings.EqualFold(p.Value, "CANCELLED")
+	}
+
+	start := ev.GetProperty(ics.ComponentPropertyDtStart)
+	if start == nil {
+		ie.Skip = "The event has no start time."
+		return ie
+	}
+
+	var allDay bool
+	var loc *time.Location
+	var err error
+	ie.Start, loc, allDay, err = parseICalTime(start, defaultLoc)
+	if err != nil {
+		ie.Skip = "The start time isn't valid."
+		return ie
+	}
+	ie.Timezone = loc.String()
+
+	// UIDs are required but not every app writes them
+	if ie.UID == "" {
+		ie.UID = icalOccurrenceUID(ie.Name, ie.Start)
+	}
+
+	// Without an end, all day events last the day and others an hour.
+	ie.End = ie.Start.Add(time.Hour)
+	if allDay {
+		ie.End = ie.Start.AddDate(0, 0, 1)
+	}
+	if p := ev.GetProperty(ics.ComponentPropertyDtEnd); p != nil {
+		if end, _, _, err := parseICalTime(p, loc); err == nil && end.After(ie.Start) {
+			ie.End = end.In(loc)
+		}
+	} else if p := ev.GetProperty(ics.ComponentPropertyDuration); p != nil {
+		if d, err := parseICalDuration(p.Value); err == nil && d > 0 {
+			ie.End = ie.Start.Add(d)
+		}
+	}
+
+	if p := ev.GetProperty(ics.ComponentPropertyLocation); p != nil {
+		ie.Location = strings.TrimSpace(p.Value)
+	}
+
+	if isWebURL(ie.Location) {
+		ie.LocationURL = ie.Location
+	} else if ie.Location != "" {
+
+		parts := strings.SplitN(ie.Location, ",", 3)
+		ie.VenueName = strings.TrimSpace(parts[0])
+		if len(parts) > 1 {
+			ie.VenueAddress = strings.TrimSpace(parts[1])
+		}
+	}
+
+	// video calls are often only given as a conference link
+	if ie.LocationURL == "" {
+		for _, name := range []string{"CONFERENCE", "X-GOOGLE-CONFERENCE"} {
+			if p := ev.GetProperty(ics.ComponentProperty(name)); p != nil && isWebURL(p.Value) {
+				ie.LocationURL = p.Value
+				break
+			}
+		}
+	}
+
+	return ie
+}
+
+/*
+ * parseICalTime parses a DATE or DATE-TIME property. It returns the time, in
+ * the location it's given in, and whether it's a date. Times in UTC or in an
+ * unknown timezone are given in defaultLoc.
+ */
+func parseICalTime(p *ics.IANAProperty, defaultLoc *time.Location) (time.Time, *time.Location, bool, error) {
+
+	loc := defaultLoc
+	if tzid, ok := p.ICalParameters["TZID"]; ok && len(tzid) == 1 {
+		loc = loadICalLocation(tzid[0], defaultLoc)
+	}
+
+	t, allDay, err := parseICalValue(strings.TrimSpace(p.Value), loc)
+	if err != nil {
+		return t, nil, false, err
+	}
+
+	return t.In(loc), loc, allDay, nil
+}
+
+/*
+ * parseICalTimes parses a property holding a list of times, such as EXDATE.
+ * Values that aren't valid are left out.
+ */
+func parseICalTimes(p *ics.IANAProperty, defaultLoc *time.Location) []time.Time {
+
+	loc := defaultLoc
+	if tzid, ok := p.ICalParameters["TZID"]; ok && len(tzid) == 1 {
+		loc = loadICalLocation(tzid[0], defaultLoc)
+	}
+
+	var times []time.Time
+	for _, value := range strings.Split(p.Value, ",") {
+
+		t, _, err := parseICalValue(strings.TrimSpace(value), loc)
+		if err == nil {
+			times = append(times, t)
+		}
+	}
+
+	return times
+}
+
+/*
+ * parseICalValue parses a DATE or DATE-TIME value, in loc unless it's in UTC.
+ */
+func parseICalValue(value string, loc *time.Location) (time.Time, bool, error) {
+
+	switch {
+	case len(value) == 8:
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(icalTimeFormat, value)
+		return t, false, err
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+
+	return t, false, err
+}
+
+/*
+ * parseICalDuration parses an RFC 5545 duration such as PT1H30M.
+ */
+func parseICalDuration(value string) (time.Duration, error) {
+
+	m := icalDuration.FindStringSubmatch(strings.TrimPrefix(value, "+"))
+	if m == nil {
+		return 0, errors.New("Invalid duration.")
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		n, _ := strconv.Atoi(m[i+1])
+		d += time.Duration(n) * unit
+	}
+
+	return d, nil
+}
+
+/*
+ * loadICalLocation loads an IANA timezone, or returns fallback when it isn't
+ * one, such as the Windows names some calendar apps use. Without a fallback,
+ * UTC is used.
+ */
+func loadICalLocation(name string, fallback *time.Location) *time.Location {
+
+	name = strings.TrimPrefix(strings.Trim(name, `"`), "/")
+
+	loc, err := time.LoadLocation(name)
+	if err == nil && name != "" && name != "Local" {
+		return loc
+	}
+
+	if fallback != nil {
+		return fallback
+	}
+
+	return time.UTC
+}
+
+/*
+ * icalOccurrenceUID returns the UID an occurrence of a recurring event is
+ * imported with.
+ */
+func icalOccurrenceUID(uid string, recurrence time.Time) string {
+	return uid + "/" + recurrence.UTC().Format(icalTimeFormat)
+}
+
+/*
+ * isWebURL reports whether s is an absolute http or https URL.
+ */
+func isWebURL(s string) bool {
+
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && !strings.ContainsAny(s, " \n")
+}
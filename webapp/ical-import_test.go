@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICalImportRecurringAcrossDST(t *testing.T) {
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("No timezone data:", err)
+	}
+
+	// an overnight event, repeating over the night of the next DST change
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, loc)
+	_, offset := day.Zone()
+	for {
+		day = day.AddDate(0, 0, 1)
+		if _, o := day.Zone(); o != offset {
+			break
+		}
+	}
+	first := day.AddDate(0, 0, -8)
+	start := time.Date(first.Year(), first.Month(), first.Day(), 22, 0, 0, 0, loc)
+
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:weekly@example.com",
+		"DTSTAMP:20240101T000000Z",
+		"SUMMARY:Weekly meetup",
+		"DTSTART;TZID=America/New_York:" + start.Format("20060102T150405"),
+		"DTEND;TZID=America/New_York:" + start.Add(8*time.Hour).Format("20060102T150405"),
+		"RRULE:FREQ=WEEKLY;COUNT=3",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := parseICalImport(strings.NewReader(ics), "UTC")
+	if err != nil {
+		t.Fatal("parseICalImport failed:", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	for _, ie := range events {
+
+		s, e := ie.Start.In(loc), ie.End.In(loc)
+		if s.Hour() != 22 || e.Hour() != 6 || e.Minute() != 0 {
+			t.Errorf("occurrence %s runs %s to %s, want 22:00 to 06:00", ie.UID, s.Format("2006-01-02 15:04 MST"), e.Format("15:04 MST"))
+		}
+	}
+}
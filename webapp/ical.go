@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * iCalendar (RFC 5545) output. Events start and end in the local time of
+ * their timezone, with a TZID, so that calendar apps show them in the right
+ * zone and keep them right across DST changes. The VTIMEZONE of each zone is
+ * generated from the Go timezone database for the years the events cover.
+ * Events in UTC, and timestamps such as DTSTAMP, are written in UTC.
+ */
+
+const icalTimeFormat = "20060102T150405Z"
+
+// icalLocalFormat is a local time, given with a TZID.
+const icalLocalFormat = "20060102T150405"
+
+/*
+ * icalCalendar is a VCALENDAR being built, one VEVENT at a time. The events
+ * are kept aside until the calendar is written since the VTIMEZONEs they use
+ * have to come first.
+ */
+type icalCalendar struct {
+	b      strings.Builder
+	events strings.Builder
+	// the earliest and latest time used in each timezone
+	zones map[string][2]time.Time
+}
+
+/*
+ * addEvent writes e as a VEVENT. The UID never changes for an event and
+ * SEQUENCE goes up every time its time, place, or description changes so
+ * that calendar apps update their copy.
+ */
+func (c *icalCalendar) addEvent(e *db.Event) {
+
+	eventURL := baseURL() + "/events/" + e.IDString()
+
+	c.line("BEGIN:VEVENT")
+	c.line("UID:" + icalUID(e))
+	c.line("DTSTAMP:" + e.UpdatedTime.UTC().Format(icalTimeFormat))
+	c.line("CREATED:" + e.CreatedTime.UTC().Format(icalTimeFormat))
+	c.line("LAST-MODIFIED:" + e.UpdatedTime.UTC().Format(icalTimeFormat))
+	c.line("SEQUENCE:" + strconv.Itoa(e.Sequence))
+	c.line("DTSTART" + c.icalTime(e.StartTime, e.Location()))
+	c.line("DTEND" + c.icalTime(e.EndTime, e.Location()))
+	c.line("SUMMARY:" + icalText(e.Name))
+
+	switch e.Status {
+	case db.EventCancelled:
+		c.line("STATUS:CANCELLED")
+	case db.EventPostponed, db.EventDraft:
+		c.line("STATUS:TENTATIVE")
+	default:
+		c.line("STATUS:CONFIRMED")
+	}
+
+	description := e.Summary
+	if e.StatusReason != "" {
+		description = strings.ToUpper(string(e.Status)) + ": " + e.StatusReason + "\n\n" + description
+	}
+	if e.Description != "" {
+		description = description + "\n\n" + e.Description
+	}
+	if agenda := icalAgenda(e); agenda != "" {
+		description = description + "\n\n" + agenda
+	}
+	description = strings.TrimSpace(description + "\n\n" + eventURL)
+	c.line("DESCRIPTION:" + icalText(description))
+
+	if e.Venue != nil {
+		c.line("LOCATION:" + icalText(e.Venue.Name+", "+e.Venue.String()))
+	} else if e.LocationURL != "" {
+		c.line("LOCATION:" + icalText(e.LocationURL))
+		c.line("CONFERENCE;VALUE=URI:" + e.LocationURL)
+	}
+
+	c.line("URL:" + eventURL)
+
+	if e.TheGroup != nil {
+		c.line("CATEGORIES:" + icalText(e.TheGroup.Name))
+	}
+
+	c.line("END:VEVENT")
+}
+
+/*
+ * icalTime returns the parameters and value of a DATE-TIME property, local
+ * with a TZID or in UTC, e.g. ";TZID=Europe/Paris:20250102T190000". The
+ * timezone is remembered so that its VTIMEZONE is written.
+ */
+func (c *icalCalendar) icalTime(t time.Time, loc *time.Location) string {
+
+	tz := loc.String()
+	if tz == "UTC" || tz == "" || tz == "Local" {
+		return ":" + t.UTC().Format(icalTimeFormat)
+	}
+
+	if c.zones == nil {
+		c.zones = make(map[string][2]time.Time)
+	}
+
+	span, ok := c.zones[tz]
+	if !ok {
+		span = [2]time.Time{t, t}
+	}
+	if t.Before(span[0]) {
+		span[0] = t
+	}
+	if t.After(span[1]) {
+		span[1] = t
+	}
+	c.zones[tz] = span
+
+	return ";TZID=" + tz + ":" + t.In(loc).Format(icalLocalFormat)
+}
+
+/*
+ * line writes a content line of an event.
+ */
+func (c *icalCalendar) line(s string) {
+	icalFold(&c.events, s)
+}
+
+/*
+ * write sends the finished calendar to the client, with the VTIMEZONEs of
+ * its events before them.
+ */
+func (c *icalCalendar) write(w http.ResponseWriter, filename string) {
+
+	zones := slices.Sorted(maps.Keys(c.zones))
+	for _, tz := range zones {
+
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			continue
+		}
+
+		for _, l := range icalTimezone(loc, c.zones[tz][0], c.zones[tz][1]) {
+			icalFold(&c.b, l)
+		}
+	}
+
+	c.b.WriteString(c.events.String())
+	icalFold(&c.b, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write([]byte(c.b.String()))
+}
+
+//==============================================================================
+// End of methods, start of functions
+//==============================================================================
+
+/*
+ * newICalCalendar starts a calendar. The name is shown by calendar apps when
+ * subscribing to a feed.
+ */
+func newICalCalendar(name string) *icalCalendar {
+
+	c := new(icalCalendar)
+
+	for _, l := range []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//" + AppName + "//" + HostnameBase + "//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + icalText(name),
+		"X-PUBLISHED-TTL:PT1H",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+	} {
+		icalFold(&c.b, l)
+	}
+
+	return c
+}
+
+/*
+ * icalAgenda lists the sessions of an event as plain text, one per line with
+ * their time in the timezone of the event.
+ */
+func icalAgenda(e *db.Event) string {
+
+	sessions := e.Agenda()
+	if len(sessions) == 0 {
+		return ""
+	}
+
+	lines := []string{"Agenda (" + e.Timezone + "):"}
+	for _, s := range sessions {
+
+		line := s.StartTime.In(e.Location()).Format("15:04") + "-" + s.EndTime.In(e.Location()).Format("15:04") + " " + s.Title
+		if s.Room != "" {
+			line += ", " + s.Room
+		}
+		if speakers := s.SpeakerNames(); speakers != "" {
+			line += " (" + speakers + ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/*
+ * icalFold writes a content line, folded at 75 octets as required by the RFC.
+ * Continuation lines start with a space which counts towards their length.
+ * Folding never splits a UTF-8 character.
+ */
+func icalFold(b *strings.Builder, s string) {
+
+	limit := 75
+
+	for len(s) > limit {
+
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+
+	b.WriteString(s + "\r\n")
+}
+
+/*
+ * icalOffset formats a UTC offset in seconds as a UTC-OFFSET value, e.g.
+ * "-0500".
+ */
+func icalOffset(seconds int) string {
+
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	offset := sign + fmt.Sprintf("%02d%02d", seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		offset += fmt.Sprintf("%02d", seconds%60)
+	}
+
+	return offset
+}
+
+/*
+ * icalText escapes a TEXT value.
+ */
+func icalText(s string) string {
+
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+/*
+ * icalTimezone returns the lines of a VTIMEZONE for loc that covers from to
+ * until. There's an observance for the offset in effect a year before from,
+ * then one for each transition found, so that apps don't need to know the
+ * zone's rules.
+ */
+func icalTimezone(loc *time.Location, from, until time.Time) []string {
+
+	start := from.AddDate(-1, 0, 0).In(loc)
+	_, offset := start.Zone()
+
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+	observance := func(t time.Time, offsetFrom int) {
+
+		name, offsetTo := t.Zone()
+
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+
+		// the onset is given in the local time before it
+		onset := t.UTC().Add(time.Duration(offsetFrom) * time.Second)
+
+		lines = append(lines,
+			"BEGIN:"+kind,
+			"DTSTART:"+onset.Format(icalLocalFormat),
+			"TZOFFSETFROM:"+icalOffset(offsetFrom),
+			"TZOFFSETTO:"+icalOffset(offsetTo),
+			"TZNAME:"+name,
+			"END:"+kind,
+		)
+	}
+
+	observance(start, offset)
+
+	// Transitions are found a day at a time, then to the second.
+	for day := start; day.Before(until); day = day.Add(24 * time.Hour) {
+
+		next := day.Add(24 * time.Hour)
+		if _, o := next.Zone(); o == offset {
+			continue
+		}
+
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+
+			mid := low.Add(high.Sub(low) / 2)
+			if _, o := mid.Zone(); o == offset {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+
+		observance(high, offset)
+		_, offset = high.Zone()
+	}
+
+	return append(lines, "END:VTIMEZONE")
+}
+
+/*
+ * icalUID returns the globally unique, permanent identifier of an event.
+ */
+func icalUID(e *db.Event) string {
+	return "event-" + e.IDString() + "@" + HostnameBase
+}
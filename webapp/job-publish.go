@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * jobPublish publishes the draft events that were scheduled to go public. It
+ * runs at startup and then every interval, which is how late an event can be
+ * published.
+ */
+func (a *app) jobPublish(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		count, err := db.PublishScheduledEvents(a.DB, time.Now())
+		if err != nil {
+			slog.Error("job: Failed to publish scheduled events.", "err", err)
+		}
+		if count > 0 {
+			slog.Info("job: Published scheduled events.", "count", count)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultReminderOffsets is used when the reminder_offsets config is empty or
+// invalid.
+var defaultReminderOffsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+/*
+ * clock tells the time. The scheduler uses it instead of time.Now so that a
+ * fixed clock can be used to test it.
+ */
+type clock interface {
+	Now() time.Time
+}
+
+/*
+ * systemClock is the real clock.
+ */
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+/*
+ * reminderMailer sends reminder emails. It's an interface so that a fake one
+ * can record emails instead of sending them.
+ */
+type reminderMailer interface {
+	SendReminder(u *db.User, e *db.Event, offset time.Duration) error
+}
+
+/*
+ * smtpReminderMailer sends reminders with the regular email setup.
+ */
+type smtpReminderMailer struct{}
+
+func (smtpReminderMailer) SendReminder(u *db.User, e *db.Event, offset time.Duration) error {
+	return sendEmailEventReminder(u, e, offset)
+}
+
+/*
+ * reminderStore claims the reminders that are due and loads what's needed to
+ * send them. It's an interface so that the scheduler can be tested without a
+ * database.
+ */
+type reminderStore interface {
+	ClaimReminders(now time.Time, offset time.Duration) ([]*db.Reminder, error)
+	ReleaseReminder(r *db.Reminder, claimedAt time.Time) error
+	GetEvent(id uint64) (*db.Event, error)
+	GetUser(id uint64) (*db.User, error)
+}
+
+/*
+ * dbReminderStore keeps track of reminders in the database, see
+ * db.ClaimReminders.
+ */
+type dbReminderStore struct {
+	DB *pgxpool.Pool
+}
+
+func (s dbReminderStore) ClaimReminders(now time.Time, offset time.Duration) ([]*db.Reminder, error) {
+	return db.ClaimReminders(s.DB, now, offset)
+}
+
+func (s dbReminderStore) ReleaseReminder(r *db.Reminder, claimedAt time.Time) error {
+	return db.ReleaseReminder(s.DB, r, claimedAt)
+}
+
+func (s dbReminderStore) GetEvent(id uint64) (*db.Event, error) {
+	return db.GetEventByID(s.DB, id)
+}
+
+func (s dbReminderStore) GetUser(id uint64) (*db.User, error) {
+	return db.GetUserByID(s.DB, id)
+}
+
+/*
+ * reminderScheduler emails everyone who RSVP'd yes or maybe to an event, once
+ * per offset before the event starts. The RSVP's reminded_time is what keeps
+ * track of it, in the database, so restarts and other replicas running the
+ * same scheduler don't send duplicates.
+ */
+type reminderScheduler struct {
+	Store   reminderStore
+	Offsets []time.Duration
+	Clock   clock
+	Mailer  reminderMailer
+}
+
+/*
+ * Run sends the reminders that are due and returns how many were sent.
+ *
+ * Offsets are processed from the shortest to the longest. That way, if the
+ * scheduler was down and several reminders are due for the same RSVP, only
+ * the closest one to the event is sent.
+ */
+func (s *reminderScheduler) Run() (int, error) {
+
+	now := s.Clock.Now()
+	sent := 0
+
+	for _, offset := range sortedOffsets(s.Offsets) {
+
+		reminders, err := s.Store.ClaimReminders(now, offset)
+		if err != nil {
+			return sent, err
+		}
+
+		// several RSVPs usually share an event
+		events := make(map[uint64]*db.Event)
+
+		for _, reminder := range reminders {
+
+			err := s.send(reminder, offset, events)
+			if err != nil {
+
+				slog.Error("job: Failed to send reminder.", "eventID", reminder.EventID, "userID", reminder.UserID, "err", err)
+
+				err = s.Store.ReleaseReminder(reminder, now)
+				if err != nil {
+					slog.Error("job: Failed to release reminder.", "eventID", reminder.EventID, "userID", reminder.UserID, "err", err)
+				}
+
+				continue
+			}
+
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+/*
+ * send emails a single claimed reminder.
+ */
+func (s *reminderScheduler) send(reminder *db.Reminder, offset time.Duration, events map[uint64]*db.Event) error {
+
+	e, ok := events[reminder.EventID]
+	if !ok {
+
+		var err error
+		e, err = s.Store.GetEvent(reminder.EventID)
+		if err != nil {
+			return err
+		}
+		events[reminder.EventID] = e
+	}
+
+	u, err := s.Store.GetUser(reminder.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.SendReminder(u, e, offset)
+}
+
+/*
+ * jobReminders runs the reminder scheduler at startup and then every interval.
+ * The interval should be a lot shorter than the smallest offset.
+ */
+func (a *app) jobReminders(offsets []time.Duration, interval time.Duration) {
+
+	s := &reminderScheduler{
+		Store:   dbReminderStore{a.DB},
+		Offsets: offsets,
+		Clock:   systemClock{},
+		Mailer:  smtpReminderMailer{},
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		sent, err := s.Run()
+		if err != nil {
+			slog.Error("job: Failed to run reminders.", "err", err)
+		}
+		if sent > 0 {
+			slog.Info("job: Sent event reminders.", "count", sent)
+		}
+
+		<-ticker.C
+	}
+}
+
+/*
+ * parseReminderOffsets parses a list of durations separated by commas, such
+ * as "24h,2h".
+ */
+func parseReminderOffsets(value string) []time.Duration {
+
+	var offsets []time.Duration
+
+	for _, field := range strings.Split(value, ",") {
+
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		offset, err := time.ParseDuration(field)
+		if err != nil || offset <= 0 {
+			slog.Error("Invalid reminder offset, using the defaults.", "offset", field, "err", err)
+			return defaultReminderOffsets
+		}
+
+		offsets = append(offsets, offset)
+	}
+
+	if len(offsets) == 0 {
+		return defaultReminderOffsets
+	}
+
+	return offsets
+}
+
+/*
+ * sortedOffsets returns a copy of offsets sorted from the shortest.
+ */
+func sortedOffsets(offsets []time.Duration) []time.Duration {
+
+	sorted := append([]time.Duration(nil), offsets...)
+	slices.Sort(sorted)
+
+	return sorted
+}
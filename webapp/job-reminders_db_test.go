@@ -0,0 +1,323 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+ * These tests run the reminder scheduler against the database, to check the
+ * claims made by db.ClaimReminders. They need a migrated database with cities,
+ * see `mage test`, and the integration build tag:
+ *
+ *	go test -tags=integration ./...
+ *
+ * The database is configured with the same DB_* environment variables as the
+ * app.
+ */
+
+/*
+ * reminderFixture holds the rows a test created, to remove them afterwards.
+ */
+type reminderFixture struct {
+	t       *testing.T
+	pool    *pgxpool.Pool
+	groupID uint64
+	userIDs []uint64
+}
+
+func TestReminderWindowsDB(t *testing.T) {
+
+	f := newReminderFixture(t)
+	u := f.user()
+
+	// far in the future so that real events aren't reminded
+	clk := &fixedClock{time.Date(2099, 1, 1, 12, 0, 0, 0, time.UTC)}
+	mailer := &recordingMailer{}
+	s := f.scheduler(clk, mailer)
+
+	tomorrow := f.event(clk.now.Add(23*time.Hour), u)
+	soon := f.event(clk.now.Add(30*time.Minute), u)
+	later := f.event(clk.now.Add(30*time.Hour), u)
+
+	f.run(s)
+
+	if got := mailer.sentTo(tomorrow); len(got) != 1 || got[0] != 24*time.Hour {
+		t.Errorf("event in 23h got reminders %v, want [24h]", got)
+	}
+
+	// it's in both windows but only the closest reminder is sent
+	if got := mailer.sentTo(soon); len(got) != 1 || got[0] != time.Hour {
+		t.Errorf("event in 30m got reminders %v, want [1h]", got)
+	}
+
+	if got := mailer.sentTo(later); len(got) != 0 {
+		t.Errorf("event in 30h got reminders %v, want none", got)
+	}
+
+	// the event in 23h enters the 1h window
+	clk.now = clk.now.Add(22*time.Hour + 30*time.Minute)
+	f.run(s)
+
+	if got := mailer.sentTo(tomorrow); len(got) != 2 || got[1] != time.Hour {
+		t.Errorf("event in 23h got reminders %v, want [24h 1h]", got)
+	}
+
+	if got := mailer.sentTo(later); len(got) != 1 || got[0] != 24*time.Hour {
+		t.Errorf("event in 30h got reminders %v, want [24h]", got)
+	}
+}
+
+func TestReminderNotSentTwiceDB(t *testing.T) {
+
+	f := newReminderFixture(t)
+	u := f.user()
+
+	clk := &fixedClock{time.Date(2099, 2, 1, 12, 0, 0, 0, time.UTC)}
+	mailer := &recordingMailer{}
+	s := f.scheduler(clk, mailer)
+
+	eventID := f.event(clk.now.Add(20*time.Hour), u)
+
+	f.run(s)
+
+	// again at the same time, and a bit later in the same window
+	f.run(s)
+	clk.now = clk.now.Add(10 * time.Minute)
+	f.run(s)
+
+	// another scheduler, as on another replica
+	f.run(f.scheduler(clk, mailer))
+
+	if got := mailer.sentTo(eventID); len(got) != 1 {
+		t.Errorf("got reminders %v, want a single one", got)
+	}
+}
+
+func TestReminderReleasedOnSendErrorDB(t *testing.T) {
+
+	f := newReminderFixture(t)
+	failing := f.user()
+	working := f.user()
+
+	clk := &fixedClock{time.Date(2099, 3, 1, 12, 0, 0, 0, time.UTC)}
+	mailer := &recordingMailer{failFor: map[uint64]bool{failing: true}}
+	s := f.scheduler(clk, mailer)
+
+	eventID := f.event(clk.now.Add(20*time.Hour), failing, working)
+
+	f.run(s)
+
+	if reminded := f.remindedTime(eventID, failing); reminded != nil {
+		t.Errorf("failed reminder kept reminded_time %v, want it released", reminded)
+	}
+
+	if reminded := f.remindedTime(eventID, working); reminded == nil {
+		t.Error("sent reminder has no reminded_time")
+	}
+
+	// the mail server is back
+	mailer.failFor = nil
+	clk.now = clk.now.Add(5 * time.Minute)
+	f.run(s)
+
+	var toFailing, toWorking int
+	for _, sent := range mailer.sent {
+		if sent.EventID != eventID {
+			continue
+		}
+		switch sent.UserID {
+		case failing:
+			toFailing++
+		case working:
+			toWorking++
+		}
+	}
+
+	if toFailing != 1 || toWorking != 1 {
+		t.Errorf("got %d reminders to the failing user and %d to the working one, want 1 each", toFailing, toWorking)
+	}
+}
+
+/*
+ * newReminderFixture connects to the test database and creates a group for
+ * the events of the test. Everything is removed when the test ends.
+ */
+func newReminderFixture(t *testing.T) *reminderFixture {
+
+	t.Helper()
+
+	ctx := context.Background()
+
+	connectionString := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
+		envOr("DB_USER", "app"),
+		envOr("DB_PASS", "APass"),
+		envOr("DB_HOST", "127.0.0.1"),
+		envOr("DB_PORT", "9001"),
+		envOr("DB_NAME", "app"),
+	)
+
+	pool, err := pgxpool.New(ctx, connectionString)
+	if err != nil {
+		t.Fatal("Failed to connect to the test database:", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		t.Fatal("Failed to connect to the test database:", err)
+	}
+
+	f := &reminderFixture{t: t, pool: pool}
+	t.Cleanup(f.cleanup)
+
+	owner := f.user()
+
+	var cityID uint64
+	err = pool.QueryRow(ctx, `SELECT id FROM cities LIMIT 1`).Scan(&cityID)
+	if err != nil {
+		t.Fatal("The test database has no cities, import them first:", err)
+	}
+
+	err = pool.QueryRow(ctx, `INSERT INTO groups (user_id, name, summary, description, slug, web_url, city_id, is_private)
+		VALUES ($1, 'Reminder tests', '', '', '', '', $2, true) RETURNING id`, owner, cityID).Scan(&f.groupID)
+	if err != nil {
+		t.Fatal("Failed to create group:", err)
+	}
+
+	return f
+}
+
+/*
+ * user creates a user and returns its ID.
+ */
+func (f *reminderFixture) user() uint64 {
+
+	f.t.Helper()
+
+	var id uint64
+	username := fmt.Sprintf("reminder-test-%d", time.Now().UnixNano())
+	err := f.pool.QueryRow(context.Background(), `INSERT INTO users (username, password, first_name, last_name)
+		VALUES ($1, '', 'Reminder', 'Test') RETURNING id`, username).Scan(&id)
+	if err != nil {
+		f.t.Fatal("Failed to create user:", err)
+	}
+
+	f.userIDs = append(f.userIDs, id)
+
+	return id
+}
+
+/*
+ * event creates a published event of an hour that the users RSVP'd yes to.
+ */
+func (f *reminderFixture) event(start time.Time, userIDs ...uint64) uint64 {
+
+	f.t.Helper()
+
+	ctx := context.Background()
+
+	var id uint64
+	err := f.pool.QueryRow(ctx, `INSERT INTO events (group_id, name, start_time, end_time, status)
+		VALUES ($1, 'Reminder test', $2, $3, 'published') RETURNING id`, f.groupID, start, start.Add(time.Hour)).Scan(&id)
+	if err != nil {
+		f.t.Fatal("Failed to create event:", err)
+	}
+
+	for _, userID := range userIDs {
+
+		_, err = f.pool.Exec(ctx, `INSERT INTO rsvps (event_id, user_id, intent, role) VALUES ($1, $2, 'yes', 'attendee')`, id, userID)
+		if err != nil {
+			f.t.Fatal("Failed to create RSVP:", err)
+		}
+	}
+
+	return id
+}
+
+/*
+ * scheduler returns a reminder scheduler with 24h and 1h reminders.
+ */
+func (f *reminderFixture) scheduler(clk clock, mailer reminderMailer) *reminderScheduler {
+
+	return &reminderScheduler{
+		Store:   dbReminderStore{f.pool},
+		Offsets: []time.Duration{24 * time.Hour, time.Hour},
+		Clock:   clk,
+		Mailer:  mailer,
+	}
+}
+
+/*
+ * run runs the scheduler and fails the test on error.
+ */
+func (f *reminderFixture) run(s *reminderScheduler) {
+
+	f.t.Helper()
+
+	_, err := s.Run()
+	if err != nil {
+		f.t.Fatal("Failed to run the scheduler:", err)
+	}
+}
+
+/*
+ * remindedTime returns the reminded_time of an RSVP.
+ */
+func (f *reminderFixture) remindedTime(eventID, userID uint64) *time.Time {
+
+	f.t.Helper()
+
+	var reminded *time.Time
+	err := f.pool.QueryRow(context.Background(), `SELECT reminded_time FROM rsvps WHERE event_id=$1 AND user_id=$2`, eventID, userID).Scan(&reminded)
+	if err != nil {
+		f.t.Fatal("Failed to get RSVP:", err)
+	}
+
+	return reminded
+}
+
+/*
+ * cleanup removes everything the test created.
+ */
+func (f *reminderFixture) cleanup() {
+
+	ctx := context.Background()
+
+	queries := []string{
+		`DELETE FROM rsvps WHERE event_id IN (SELECT id FROM events WHERE group_id=$1)`,
+		`DELETE FROM events WHERE group_id=$1`,
+		`DELETE FROM groups WHERE id=$1`,
+	}
+	for _, q := range queries {
+
+		_, err := f.pool.Exec(ctx, q, f.groupID)
+		if err != nil {
+			f.t.Error("Failed to clean up:", err)
+		}
+	}
+
+	for _, id := range f.userIDs {
+		f.pool.Exec(ctx, `DELETE FROM users WHERE id=$1`, id)
+	}
+
+	f.pool.Close()
+}
+
+/*
+ * envOr returns the environment variable, or value when it isn't set.
+ */
+func envOr(name, value string) string {
+
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eventhunt-org/webapp/framework"
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * fixedClock is a clock that only moves when the test says so.
+ */
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+/*
+ * sentReminder is a reminder the recording mailer was asked to send.
+ */
+type sentReminder struct {
+	UserID  uint64
+	EventID uint64
+	Offset  time.Duration
+}
+
+/*
+ * recordingMailer records reminders instead of sending them. Sending to the
+ * users in failFor returns an error.
+ */
+type recordingMailer struct {
+	sent    []sentReminder
+	failFor map[uint64]bool
+}
+
+func (m *recordingMailer) SendReminder(u *db.User, e *db.Event, offset time.Duration) error {
+
+	if m.failFor[u.ID] {
+		return errors.New("mail server is down")
+	}
+
+	m.sent = append(m.sent, sentReminder{u.ID, e.ID, offset})
+
+	return nil
+}
+
+/*
+ * sentTo returns the offsets of the reminders recorded for an event, in the
+ * order they were sent.
+ */
+func (m *recordingMailer) sentTo(eventID uint64) []time.Duration {
+
+	var offsets []time.Duration
+
+	for _, s := range m.sent {
+		if s.EventID == eventID {
+			offsets = append(offsets, s.Offset)
+		}
+	}
+
+	return offsets
+}
+
+/*
+ * memoryRSVP is an RSVP kept by memoryReminderStore.
+ */
+type memoryRSVP struct {
+	eventID  uint64
+	userID   uint64
+	start    time.Time
+	reminded *time.Time
+}
+
+/*
+ * memoryReminderStore keeps RSVPs in memory and claims them with the same
+ * rule as db.ClaimReminders.
+ */
+type memoryReminderStore struct {
+	rsvps []*memoryRSVP
+}
+
+func (s *memoryReminderStore) ClaimReminders(now time.Time, offset time.Duration) ([]*db.Reminder, error) {
+
+	var reminders []*db.Reminder
+
+	for _, r := range s.rsvps {
+
+		at := r.start.Add(-offset)
+		if !r.start.After(now) || at.After(now) || (r.reminded != nil && !r.reminded.Before(at)) {
+			continue
+		}
+
+		reminders = append(reminders, &db.Reminder{EventID: r.eventID, UserID: r.userID, Previous: r.reminded})
+		claimed := now
+		r.reminded = &claimed
+	}
+
+	return reminders, nil
+}
+
+func (s *memoryReminderStore) ReleaseReminder(reminder *db.Reminder, claimedAt time.Time) error {
+
+	for _, r := range s.rsvps {
+		if r.eventID == reminder.EventID && r.userID == reminder.UserID && r.reminded != nil && r.reminded.Equal(claimedAt) {
+			r.reminded = reminder.Previous
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryReminderStore) GetEvent(id uint64) (*db.Event, error) {
+	return &db.Event{BaseModel: framework.BaseModel{ID: id}}, nil
+}
+
+func (s *memoryReminderStore) GetUser(id uint64) (*db.User, error) {
+	return &db.User{BaseModel: framework.BaseModel{ID: id}}, nil
+}
+
+func TestParseReminderOffsets(t *testing.T) {
+
+	tests := map[string][]time.Duration{
+		"24h,2h":      {24 * time.Hour, 2 * time.Hour},
+		" 1h , 30m ,": {time.Hour, 30 * time.Minute},
+		"48h":         {48 * time.Hour},
+		"":            defaultReminderOffsets,
+		" , ":         defaultReminderOffsets,
+		"24h,soon":    defaultReminderOffsets,
+		"24h,-1h":     defaultReminderOffsets,
+		"0s":          defaultReminderOffsets,
+	}
+
+	for value, want := range tests {
+		if got := parseReminderOffsets(value); !slices.Equal(got, want) {
+			t.Errorf("parseReminderOffsets(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestSortedOffsets(t *testing.T) {
+
+	offsets := []time.Duration{24 * time.Hour, time.Hour, 2 * time.Hour}
+
+	got := sortedOffsets(offsets)
+	if want := []time.Duration{time.Hour, 2 * time.Hour, 24 * time.Hour}; !slices.Equal(got, want) {
+		t.Errorf("sortedOffsets(%v) = %v, want %v", offsets, got, want)
+	}
+
+	if offsets[0] != 24*time.Hour {
+		t.Error("sortedOffsets changed its argument")
+	}
+}
+
+func TestReminderWindows(t *testing.T) {
+
+	clk := &fixedClock{time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := &memoryReminderStore{rsvps: []*memoryRSVP{
+		{eventID: 1, userID: 1, start: clk.now.Add(23 * time.Hour)},
+		{eventID: 2, userID: 1, start: clk.now.Add(30 * time.Minute)},
+		{eventID: 3, userID: 1, start: clk.now.Add(30 * time.Hour)},
+		{eventID: 4, userID: 1, start: clk.now.Add(-time.Minute)},
+	}}
+	mailer := &recordingMailer{}
+	s := &reminderScheduler{
+		Store:   store,
+		Offsets: []time.Duration{24 * time.Hour, time.Hour},
+		Clock:   clk,
+		Mailer:  mailer,
+	}
+
+	sent, err := s.Run()
+	if err != nil {
+		t.Fatal("Run failed:", err)
+	}
+
+	if sent != 2 {
+		t.Errorf("Run sent %d reminders, want 2", sent)
+	}
+
+	if got := mailer.sentTo(1); !slices.Equal(got, []time.Duration{24 * time.Hour}) {
+		t.Errorf("event in 23h got reminders %v, want [24h]", got)
+	}
+
+	// it's in both windows but only the closest reminder is sent
+	if got := mailer.sentTo(2); !slices.Equal(got, []time.Duration{time.Hour}) {
+		t.Errorf("event in 30m got reminders %v, want [1h]", got)
+	}
+
+	if got := mailer.sentTo(3); len(got) != 0 {
+		t.Errorf("event in 30h got reminders %v, want none", got)
+	}
+
+	if got := mailer.sentTo(4); len(got) != 0 {
+		t.Errorf("event that started got reminders %v, want none", got)
+	}
+
+	// nothing new is due a bit later
+	clk.now = clk.now.Add(10 * time.Minute)
+	if sent, _ := s.Run(); sent != 0 {
+		t.Errorf("second run sent %d reminders, want none", sent)
+	}
+
+	// the event in 23h enters the 1h window, the one in 30h the 24h window
+	clk.now = clk.now.Add(22 * time.Hour)
+	s.Run()
+
+	if got := mailer.sentTo(1); !slices.Equal(got, []time.Duration{24 * time.Hour, time.Hour}) {
+		t.Errorf("event in 23h got reminders %v, want [24h 1h]", got)
+	}
+
+	if got := mailer.sentTo(3); !slices.Equal(got, []time.Duration{24 * time.Hour}) {
+		t.Errorf("event in 30h got reminders %v, want [24h]", got)
+	}
+}
+
+func TestReminderReleasedOnSendError(t *testing.T) {
+
+	clk := &fixedClock{time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := &memoryReminderStore{rsvps: []*memoryRSVP{
+		{eventID: 1, userID: 1, start: clk.now.Add(20 * time.Hour)},
+		{eventID: 1, userID: 2, start: clk.now.Add(20 * time.Hour)},
+	}}
+	mailer := &recordingMailer{failFor: map[uint64]bool{1: true}}
+	s := &reminderScheduler{
+		Store:   store,
+		Offsets: []time.Duration{24 * time.Hour},
+		Clock:   clk,
+		Mailer:  mailer,
+	}
+
+	s.Run()
+
+	if store.rsvps[0].reminded != nil {
+		t.Errorf("failed reminder kept reminded time %v, want it released", store.rsvps[0].reminded)
+	}
+
+	if store.rsvps[1].reminded == nil {
+		t.Error("sent reminder has no reminded time")
+	}
+
+	// the mail server is back
+	mailer.failFor = nil
+	clk.now = clk.now.Add(5 * time.Minute)
+	s.Run()
+
+	want := []sentReminder{
+		{UserID: 2, EventID: 1, Offset: 24 * time.Hour},
+		{UserID: 1, EventID: 1, Offset: 24 * time.Hour},
+	}
+	if !slices.Equal(mailer.sent, want) {
+		t.Errorf("sent %v, want %v", mailer.sent, want)
+	}
+}
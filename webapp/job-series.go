@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * jobSeries keeps the upcoming occurrences of every recurring series created
+ * as events, up to db.SeriesHorizon. Without it, a series would run out of
+ * events once its horizon passes. Series of archived groups are left as they
+ * are. It runs at startup and then every interval.
+ */
+func (a *app) jobSeries(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		series, err := db.GetSeriesActive(a.DB)
+		if err != nil {
+			slog.Error("job: Failed to get recurring series.", "err", err)
+		}
+
+		for _, s := range series {
+
+			err := a.syncSeries(s, 0)
+			if err != nil {
+				slog.Error("job: Failed to sync recurring series.", "seriesID", s.ID, "err", err)
+			}
+		}
+
+		<-ticker.C
+	}
+}
@@ -28,5 +28,5 @@ func Test() error {
 		return err
 	}
 
-	return sh.Run("gotestsum", "--junitfile=unit-tests.xml", "--", "-coverprofile=coverage.txt", "-covermode=atomic", "./...")
+	return sh.Run("gotestsum", "--junitfile=unit-tests.xml", "--", "-tags=integration", "-coverprofile=coverage.txt", "-covermode=atomic", "./...")
 }
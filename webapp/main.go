@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/eventhunt-org/webapp/framework"
 
@@ -38,6 +39,9 @@ func main() {
 	viper.SetDefault("db_name", "app")
 
 	viper.SetDefault("auth_session_key", "CHANGE_ME")
+	viper.SetDefault("ticket_signing_key", "")
+
+	viper.SetDefault("reminder_offsets", "24h,2h")
 
 	// Attempt to load config values from the `.env` file. If the file is not
 	// found, that's okay.
@@ -50,6 +54,7 @@ func main() {
 
 	// setup app global variables
 	store = sessions.NewCookieStore([]byte(viper.GetString("auth_session_key")))
+	hostname = viper.GetString("app_host")
 
 	/*
 	 * Setup Logging. The style of log output will vary depending on the
@@ -105,13 +110,42 @@ func main() {
 		log.Fatal("Creating inner app failed.")
 	}
 
-	a := app{innerApp}
+	// The fake provider confirms orders without charging anyone, so it's only
+	// picked when nothing is configured outside of production. Without a
+	// provider, only free tickets can be created and sold.
+	var payments PaymentProvider
+	provider := viper.GetString("payment_provider")
+	if viper.GetString("app_environment") == "production" && (provider == "" || provider == "fake") {
+		slog.Warn("No payment provider is set up, paid tickets are disabled. Set PAYMENT_PROVIDER to enable them.")
+	} else {
+
+		if provider == "" {
+			provider = "fake"
+		}
+
+		payments, err = newPaymentProvider(provider)
+		if err != nil {
+			slog.Error("Setting up payments failed, paid tickets are disabled.", "err", err)
+		}
+	}
+
+	// tickets are signed with the session key unless they have their own
+	ticketKey := viper.GetString("ticket_signing_key")
+	if ticketKey == "" {
+		ticketKey = viper.GetString("auth_session_key")
+	}
+
+	a := app{App: innerApp, Payments: payments, TicketKey: []byte(ticketKey)}
 
 	a.Initialize(
 		os.Getenv("APP_THEME_ROOT"),
 		"original",
 	)
 
+	go a.jobSeries(time.Hour)
+	go a.jobPublish(time.Minute)
+	go a.jobReminders(parseReminderOffsets(viper.GetString("reminder_offsets")), 5*time.Minute)
+
 	slog.Info("App initialized.", "mode", environment)
 	slog.Info(fmt.Sprintf("The webapp can be viewed at http://%s:%d", viper.GetString("app_host"), viper.GetUint16("app_port")))
 
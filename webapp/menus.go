@@ -105,5 +105,11 @@ func setupMenus() {
 			"fa-solid fa-users",
 			"",
 		),
+		MenuItem(
+			"Near Me",
+			"/near",
+			"fa-solid fa-location-dot",
+			"",
+		),
 	)
 }
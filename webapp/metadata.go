@@ -0,0 +1,191 @@
+package main
+
+import (
+	"time"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+
+	"github.com/spf13/viper"
+)
+
+/*
+ * pageMeta describes a page to search engines and to the apps its links are
+ * shared in, with OpenGraph and Twitter card tags and schema.org JSON-LD. It's
+ * passed to renderPage as Meta and the base template adds it to the head.
+ */
+type pageMeta struct {
+	SiteName    string
+	Title       string
+	Description string
+	URL         string
+	// OpenGraph type of the page
+	Type  string
+	Image string
+	// Pages of private groups and drafts are only kept out of search engines,
+	// nothing else about them is given.
+	NoIndex bool
+	JSONLD  map[string]any
+}
+
+/*
+ * eventMeta returns the metadata of an event page, with a schema.org Event.
+ */
+func eventMeta(e *db.Event) *pageMeta {
+
+	if e.IsDraft() || e.TheGroup.IsPrivate {
+		return &pageMeta{NoIndex: true}
+	}
+
+	base := baseURL()
+	eventURL := base + "/events/" + e.IDString()
+
+	m := &pageMeta{
+		SiteName:    AppName,
+		Title:       e.Name,
+		Description: metaDescription(e.Summary, e.Description),
+		URL:         eventURL,
+		Type:        "website",
+		Image:       base + "/assets/img/favicon.png",
+	}
+
+	status := "https://schema.org/EventScheduled"
+	if e.IsCancelled() {
+		status = "https://schema.org/EventCancelled"
+	} else if e.IsPostponed() {
+		status = "https://schema.org/EventPostponed"
+	}
+
+	var locations []any
+	mode := "https://schema.org/OfflineEventAttendanceMode"
+
+	if e.Venue != nil {
+
+		address := map[string]any{
+			"@type":         "PostalAddress",
+			"streetAddress": e.Venue.Address,
+		}
+		if city, err := db.GetCityByID(e.Venue.DB, e.Venue.CityID); err == nil {
+			address["addressLocality"] = city.Name
+			address["addressRegion"] = city.Admin1
+		}
+
+		place := map[string]any{
+			"@type":   "Place",
+			"name":    e.Venue.Name,
+			"address": address,
+		}
+		if e.Venue.WebURL != "" {
+			place["url"] = e.Venue.WebURL
+		}
+
+		locations = append(locations, place)
+	}
+
+	if e.LocationURL != "" {
+
+		// the online link of hybrid events is kept to online attendees
+		onlineURL := e.LocationURL
+		if e.IsHybrid() {
+			onlineURL = eventURL
+			mode = "https://schema.org/MixedEventAttendanceMode"
+		} else {
+			mode = "https://schema.org/OnlineEventAttendanceMode"
+		}
+
+		locations = append(locations, map[string]any{
+			"@type": "VirtualLocation",
+			"url":   onlineURL,
+		})
+	}
+
+	m.JSONLD = map[string]any{
+		"@context":            "https://schema.org",
+		"@type":               "Event",
+		"name":                e.Name,
+		"description":         m.Description,
+		"url":                 eventURL,
+		"image":               m.Image,
+		"startDate":           e.LocalStart().Format(time.RFC3339),
+		"endDate":             e.LocalEnd().Format(time.RFC3339),
+		"eventStatus":         status,
+		"eventAttendanceMode": mode,
+		"organizer":           groupOrganization(base, e.TheGroup),
+	}
+
+	switch len(locations) {
+	case 1:
+		m.JSONLD["location"] = locations[0]
+	case 2:
+		m.JSONLD["location"] = locations
+	}
+
+	return m
+}
+
+/*
+ * groupMeta returns the metadata of a group page, with the group as a
+ * schema.org Organization.
+ */
+func groupMeta(g *db.Group) *pageMeta {
+
+	if g.IsPrivate || g.IsArchived() {
+		return &pageMeta{NoIndex: true}
+	}
+
+	base := baseURL()
+
+	m := &pageMeta{
+		SiteName:    AppName,
+		Title:       g.Name,
+		Description: metaDescription(g.Summary, g.Description),
+		URL:         base + "/groups/" + g.IDString(),
+		Type:        "website",
+		Image:       base + "/assets/img/favicon.png",
+	}
+
+	m.JSONLD = groupOrganization(base, g)
+	m.JSONLD["@context"] = "https://schema.org"
+	m.JSONLD["description"] = m.Description
+
+	return m
+}
+
+/*
+ * groupOrganization returns the group as a schema.org Organization, which is
+ * how events name their organizer.
+ */
+func groupOrganization(base string, g *db.Group) map[string]any {
+
+	org := map[string]any{
+		"@type": "Organization",
+		"name":  g.Name,
+		"url":   base + "/groups/" + g.IDString(),
+	}
+
+	if g.WebURL != "" {
+		org["sameAs"] = g.WebURL
+	}
+
+	return org
+}
+
+/*
+ * metaDescription returns the summary, or the start of the description when
+ * there's no summary.
+ */
+func metaDescription(summary, description string) string {
+
+	if summary != "" {
+		return summary
+	}
+
+	return truncateText(description, 200)
+}
+
+/*
+ * baseURL returns the configured scheme and hostname of the app, for links
+ * that have to be absolute. The request host isn't used as it can be spoofed.
+ */
+func baseURL() string {
+	return viper.GetString("app_scheme") + "://" + hostname
+}
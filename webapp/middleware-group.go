@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 	"github.com/eventhunt-org/webapp/webapp/db"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 /*
@@ -27,6 +29,10 @@ func (a *app) middlewareGroup(next http.Handler) http.Handler {
 		}
 
 		g, err := db.GetGroupByID(a.DB, gID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			a.util404Get(w, r)
+			return
+		}
 		if err != nil {
 			slog.Error("middleware: Failed to load group from DB.", "id", gID, "err", err)
 			respondWithError(w, 500, err.Error())
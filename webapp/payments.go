@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/eventhunt-org/webapp/webapp/db"
+)
+
+/*
+ * PaymentProvider takes payments for ticket orders and refunds them. The
+ * provider is picked with the payment_provider config.
+ */
+type PaymentProvider interface {
+	// Name is stored with the orders paid through the provider.
+	Name() string
+	// Charge takes the amount of the order with the payment details the
+	// buyer submitted, and returns the provider's reference for the payment.
+	Charge(o *db.Order, token string) (string, error)
+	// Refund gives the whole amount of a paid order back.
+	Refund(o *db.Order) error
+}
+
+// fakeDeclinedCard is the card number the fake provider declines.
+const fakeDeclinedCard = "4000000000000002"
+
+/*
+ * fakePaymentProvider accepts any card number, except fakeDeclinedCard, and
+ * doesn't move any money. It's meant for development.
+ */
+type fakePaymentProvider struct{}
+
+func (fakePaymentProvider) Name() string { return "fake" }
+
+func (fakePaymentProvider) Charge(o *db.Order, token string) (string, error) {
+
+	token = strings.ReplaceAll(strings.TrimSpace(token), " ", "")
+
+	if token == "" {
+		return "", errors.New("A card number is required.")
+	}
+
+	if token == fakeDeclinedCard {
+		return "", errors.New("The card was declined.")
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	ref := "fake_" + hex.EncodeToString(b)
+
+	slog.Debug("Fake payment.", "orderID", o.ID, "amount", o.AmountString(), "ref", ref)
+
+	return ref, nil
+}
+
+func (fakePaymentProvider) Refund(o *db.Order) error {
+
+	slog.Debug("Fake refund.", "orderID", o.ID, "amount", o.AmountString(), "ref", o.ProviderRef)
+
+	return nil
+}
+
+/*
+ * newPaymentProvider returns the payment provider with that name.
+ */
+func newPaymentProvider(name string) (PaymentProvider, error) {
+
+	switch name {
+	case "fake":
+		return fakePaymentProvider{}, nil
+	}
+
+	return nil, errors.New("Unknown payment provider \"" + name + "\".")
+}
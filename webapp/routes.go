@@ -45,21 +45,65 @@ func (a *app) initializeRoutes() {
 		r.Use(a.middlewareUser)
 
 		r.Get("/", a.homepage)
+		r.Get("/near", a.nearbyGet)
 
 		// Events
 		r.Route("/events", func(r chi.Router) {
 			r.Get("/", a.eventsIndex)
 			r.With(a.middlewareLIO).Get("/{:new|schedule}", a.eventsNewAlias)
+			r.With(a.middlewareEvent).Get("/{event-id:[0-9]+}.ics", a.calendarEventICS)
 			r.Route("/{event-id:[0-9]+}", func(r chi.Router) {
 				r.Use(a.middlewareEvent)
 				r.Get("/", a.eventsSingle)
 				r.Group(func(r chi.Router) {
 					r.Use(a.middlewareLIO)
 
+					r.Get("/edit", a.eventsEditGet)
+					r.Post("/edit", a.eventsEditPost)
+					r.Post("/skip", a.seriesSkipPost)
+					r.Post("/publish", a.eventsPublishPost)
+					r.Post("/{action:cancel|postpone}", a.eventsStatusPost)
 					r.Get("/new-venue", a.venueNew)
 					r.Post("/new-venue/irl", a.venueNewPost)
 					r.Post("/new-venue/www", a.venueWWWPost)
-					r.Get("/rsvp/{status:yes|maybe|no}", a.rsvpsInput)
+					r.Get("/rsvp/{status:yes|in-person|online|maybe|no}", a.rsvpsInput)
+					r.Post("/rsvp/{status:yes|in-person|online}", a.rsvpsInputPost)
+					r.Get("/check-in", a.checkinGet)
+					r.Post("/check-in", a.checkinPost)
+					r.Post("/check-in/walk-in", a.checkinWalkInPost)
+					r.Get("/scan", a.scanGet)
+					r.Post("/scan", a.scanPost)
+					r.Get("/ticket.png", a.ticketQRGet)
+					r.Get("/waitlist", a.rsvpsWaitlistGet)
+					r.Post("/waitlist", a.rsvpsWaitlistPost)
+					r.Get("/duplicate", a.duplicateEventGet)
+					r.Post("/duplicate", a.duplicateEventPost)
+					r.Get("/staff", a.staffGet)
+					r.Post("/staff", a.staffPost)
+					r.Post("/staff/{user-id:[0-9]+}/remove", a.staffRemovePost)
+					r.Get("/questions", a.questionsGet)
+					r.Post("/questions", a.questionsPost)
+					r.Post("/questions/{question-id:[0-9]+}/delete", a.questionsDeletePost)
+					r.Get("/answers", a.answersGet)
+					r.Get("/answers.csv", a.answersCSVGet)
+					r.Get("/feedback", a.feedbackGet)
+					r.Post("/feedback", a.feedbackPost)
+					r.Get("/ratings", a.ratingsGet)
+					r.Get("/agenda", a.agendaGet)
+					r.Post("/agenda", a.agendaPost)
+					r.Post("/agenda/{session-id:[0-9]+}/{action:up|down|delete}", a.agendaSessionPost)
+					r.Get("/tickets", a.ticketsGet)
+					r.Post("/tickets", a.ticketsPost)
+					r.Post("/tickets/{ticket-id:[0-9]+}/delete", a.ticketsDeletePost)
+					r.Get("/orders", a.ordersGet)
+					r.Post("/orders/{order-id:[0-9]+}/refund", a.ordersRefundPost)
+					r.Get("/attendees", a.attendeesGet)
+					r.Get("/attendees.{format:csv|json}", a.attendeesExportGet)
+					r.Get("/message", a.staffMessageGet)
+					r.Post("/message", a.staffMessagePost)
+					r.Post("/comments", a.commentsPost)
+					r.Post("/comments/{action:lock|unlock}", a.commentsLockPost)
+					r.Post("/comments/{comment-id:[0-9]+}/{action:pin|unpin|hide|unhide|delete}", a.commentsModeratePost)
 				})
 			})
 		})
@@ -73,12 +117,37 @@ func (a *app) initializeRoutes() {
 			r.Route("/{group-id:[0-9]+}", func(r chi.Router) {
 				r.Use(a.middlewareGroup)
 				r.Get("/", a.groupsSingle)
+				r.Get("/events.ics", a.calendarGroupICS)
 				r.Get("/{:new|schedule}", a.eventsNew)
 				r.With(a.middlewareLIO).Post("/{:new|schedule}", a.eventsNewPost)
 				r.With(a.middlewareLIO).Get("/join", a.groupsJoin)
+				r.With(a.middlewareLIO).Get("/attendance", a.groupsAttendanceGet)
+				r.With(a.middlewareLIO).Get("/ratings", a.groupsRatingsGet)
+				r.With(a.middlewareLIO).Get("/embed", a.widgetEmbedGet)
+				r.With(a.middlewareLIO).Get("/duplicate", a.duplicateGroupGet)
+				r.With(a.middlewareLIO).Post("/duplicate", a.duplicateGroupPost)
+				r.With(a.middlewareLIO).Get("/import", a.importGet)
+				r.With(a.middlewareLIO).Post("/import", a.importPost)
+				r.With(a.middlewareLIO).Get("/edit", a.groupsEditGet)
+				r.With(a.middlewareLIO).Post("/edit", a.groupsEditPost)
+				r.With(a.middlewareLIO).Post("/{action:archive|unarchive}", a.groupsArchivePost)
+				r.With(a.middlewareLIO).Get("/delete", a.groupsDeleteGet)
+				r.With(a.middlewareLIO).Post("/delete", a.groupsDeletePost)
 			})
 		})
 
+		// Users
+		r.Route("/users/me", func(r chi.Router) {
+			// calendar apps authenticate with a token instead of a session
+			r.Get("/calendar.ics", a.calendarUserICS)
+			r.With(a.middlewareLIO).Get("/calendar", a.calendarUserGet)
+			r.With(a.middlewareLIO).Post("/calendar", a.calendarUserPost)
+			r.With(a.middlewareLIO).Get("/home-city", a.usersHomeCityGet)
+			r.With(a.middlewareLIO).Post("/home-city", a.usersHomeCityPost)
+			r.With(a.middlewareLIO).Get("/privacy", a.usersPrivacyGet)
+			r.With(a.middlewareLIO).Post("/privacy", a.usersPrivacyPost)
+		})
+
 		r.Group(func(r chi.Router) {
 			r.Use(a.middlewareLIO)
 			// For random pages
@@ -91,5 +160,8 @@ func (a *app) initializeRoutes() {
 	// This one is outside of LIO because sometimes for debugging purposes, we
 	// have a cookie but no user. This allows us to reset the session basically.
 	a.Router.Get("/logout", a.authLogout)
+	// The widget is embedded on other websites so it doesn't use the session.
+	a.Router.Get("/widgets/groups/{group-id:[0-9]+}", a.widgetGet)
+	a.Router.Get("/widgets/groups/{group-id:[0-9]+}.js", a.widgetJSGet)
 	a.Router.NotFound(http.HandlerFunc(a.util404Get))
 }
@@ -2,6 +2,22 @@
 
 // run when full page body has loaded
 document.addEventListener( "DOMContentLoaded", () => {
+
+	// show event times in the viewer's timezone when it isn't the event's
+	let viewerZone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+	document.querySelectorAll( "[data-viewer-time]" ).forEach(( el ) => {
+
+		if ( el.dataset.timezone == viewerZone ){
+			return;
+		}
+
+		let options = { dateStyle: "medium", timeStyle: "short", timeZoneName: "short" };
+		let start = new Date( el.dataset.start ).toLocaleString( undefined, options );
+		let end = new Date( el.dataset.end ).toLocaleString( undefined, options );
+
+		el.querySelector( ".time" ).textContent = start + " - " + end;
+		el.hidden = false;
+	});
 });
 
 // Log metadata to console
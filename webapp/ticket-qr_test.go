@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTicketCodeRoundTrip(t *testing.T) {
+
+	a := &app{TicketKey: []byte("test ticket key")}
+
+	for eventID := uint64(1); eventID <= 100; eventID++ {
+		for userID := uint64(1); userID <= 100; userID++ {
+
+			code := a.ticketCode(eventID, userID)
+
+			gotEvent, gotUser, err := a.parseTicketCode(code)
+			if err != nil {
+				t.Fatalf("parseTicketCode(%q) failed: %s", code, err)
+			}
+
+			if gotEvent != eventID || gotUser != userID {
+				t.Fatalf("parseTicketCode(%q) = %d, %d, want %d, %d", code, gotEvent, gotUser, eventID, userID)
+			}
+		}
+	}
+}
+
+func TestTicketCodeRejected(t *testing.T) {
+
+	a := &app{TicketKey: []byte("test ticket key")}
+	other := &app{TicketKey: []byte("another key")}
+
+	code := a.ticketCode(12, 34)
+	sig := code[strings.LastIndex(code, "-")+1:]
+
+	codes := map[string]string{
+		"empty":          "",
+		"no signature":   "12-34",
+		"other user":     "12-35-" + sig,
+		"other event":    "13-34-" + sig,
+		"other key":      other.ticketCode(12, 34),
+		"bad signature":  code + "x",
+		"not a number":   "x-34-" + a.ticketSignature("x-34"),
+		"negative user":  "12--34-" + a.ticketSignature("12-"),
+		"missing events": "-34-" + a.ticketSignature("-34"),
+	}
+
+	for name, code := range codes {
+
+		_, _, err := a.parseTicketCode(code)
+		if err == nil {
+			t.Errorf("%s: parseTicketCode(%q) succeeded, want an error", name, code)
+		}
+	}
+}